@@ -1,16 +1,57 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"gswarm-sidecar/internal/config"
+	"gswarm-sidecar/internal/logs"
 	"gswarm-sidecar/internal/monitor"
 )
 
 func main() {
+	testRules := flag.String("test-rules", "", "parse a sample log file with the configured log rules, print the resulting events and exit")
+	testRulesAs := flag.String("as", "", "with -test-rules, select rules as if the sample were this log file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [validate | print-config]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Commands:\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  validate\tcheck the config, print every problem found and exit\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  print-config\tprint the config merged with GSWARM_* environment overrides and defaults, secrets masked, and exit\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Flags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	switch flag.Arg(0) {
+	case "":
+	case "validate":
+		os.Exit(validate(os.Stdout))
+	case "print-config":
+		os.Exit(printConfig(os.Stdout))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *testRules != "" {
+		// A rule test only needs the log settings, so other problems do not
+		// block it.
+		cfg, err := config.Read()
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		if err := logs.RunRuleTest(cfg, *testRules, *testRulesAs, os.Stdout); err != nil {
+			log.Fatalf("Rule test failed: %v", err)
+		}
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -25,11 +66,71 @@ func main() {
 		log.Fatalf("Failed to start monitor: %v", err)
 	}
 
-	// Wait for shutdown signal
+	// Reload the config on SIGHUP until a shutdown signal arrives
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		log.Printf("Received SIGHUP, reloading config")
+		if err := monitor.Reload(); err != nil {
+			log.Printf("[ERROR] Failed to reload config, keeping the current one: %v", err)
+		}
+	}
 
 	// Graceful shutdown
 	monitor.Stop()
 }
+
+// validate checks the config file and writes every problem found to w. It
+// returns the process exit code: 0 when the config is valid, 1 otherwise.
+func validate(w io.Writer) int {
+	path := config.Path()
+	cfg, err := config.Read()
+	if err != nil {
+		fmt.Fprintf(w, "%s: %v\n", path, err)
+		return 1
+	}
+
+	var problems []string
+	var verr *config.ValidationError
+	if err := cfg.Validate(); errors.As(err, &verr) {
+		for _, p := range verr.Problems {
+			problems = append(problems, p.String())
+		}
+	} else if err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, err := range logs.CheckConfig(cfg) {
+		problems = append(problems, "log_monitoring: "+err.Error())
+	}
+
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "%s: OK\n", path)
+		return 0
+	}
+	fmt.Fprintf(w, "%s: %d problem(s)\n", path, len(problems))
+	for _, p := range problems {
+		fmt.Fprintf(w, "  %s\n", p)
+	}
+	return 1
+}
+
+// printConfig writes the merged config to w with secrets masked. It returns
+// the process exit code.
+func printConfig(w io.Writer) int {
+	cfg, err := config.Read()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", config.Path(), err)
+		return 1
+	}
+	data, err := cfg.MaskedYAML()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to print config: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(w, "# %s with GSWARM_* environment overrides and defaults applied\n", config.Path())
+	w.Write(data)
+	return 0
+}
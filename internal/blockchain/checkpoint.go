@@ -0,0 +1,59 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const checkpointFile = "blockchain_checkpoint.json"
+
+// checkpoint records the last block whose contract logs have been delivered.
+type checkpoint struct {
+	ContractAddress string `json:"contract_address"`
+	LastBlock       uint64 `json:"last_block"`
+}
+
+func checkpointPath(dataPath string) string {
+	return filepath.Join(dataPath, checkpointFile)
+}
+
+// loadCheckpoint returns the persisted checkpoint for contract. ok is false when
+// there is none, or when it belongs to a different contract.
+func loadCheckpoint(path, contract string) (cp checkpoint, ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cp, false, nil
+		}
+		return cp, false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return cp, false, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+	if !strings.EqualFold(cp.ContractAddress, contract) {
+		return cp, false, nil
+	}
+	return cp, true, nil
+}
+
+// saveCheckpoint atomically replaces the checkpoint file.
+func saveCheckpoint(path string, cp checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create checkpoint dir: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to persist checkpoint: %w", err)
+	}
+	return nil
+}
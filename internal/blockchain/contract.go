@@ -0,0 +1,117 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// contract bundles a client, address and ABI for read-only contract calls.
+type contract struct {
+	client  chainClient
+	address common.Address
+	abi     *abi.ABI
+}
+
+// callRequest is a single contract call in a batch.
+type callRequest struct {
+	method string
+	args   []interface{}
+}
+
+// callResult holds the unpacked outputs of a callRequest, or its error.
+type callResult struct {
+	out []interface{}
+	err error
+}
+
+// rpcClientProvider is implemented by ethclient.Client and exposes the raw RPC
+// client needed for JSON-RPC batching.
+type rpcClientProvider interface {
+	Client() *rpc.Client
+}
+
+// call packs args for method, executes it at the latest block and returns the
+// unpacked outputs.
+func (c *contract) call(ctx context.Context, method string, args ...interface{}) ([]interface{}, error) {
+	input, err := c.abi.Pack(method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack %s: %w", method, err)
+	}
+	res, err := c.client.CallContract(ctx, ethereum.CallMsg{
+		To:   &c.address,
+		Data: input,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("call to %s failed: %w", method, err)
+	}
+	return c.unpack(method, res)
+}
+
+// batchCall executes all requests in a single JSON-RPC batch when the client
+// supports it, and falls back to sequential calls otherwise. Results are
+// returned in request order.
+func (c *contract) batchCall(ctx context.Context, reqs []callRequest) []callResult {
+	results := make([]callResult, len(reqs))
+
+	provider, ok := c.client.(rpcClientProvider)
+	if !ok || len(reqs) < 2 {
+		for i, req := range reqs {
+			results[i].out, results[i].err = c.call(ctx, req.method, req.args...)
+		}
+		return results
+	}
+
+	elems := make([]rpc.BatchElem, 0, len(reqs))
+	index := make([]int, 0, len(reqs))
+	raw := make([]hexutil.Bytes, len(reqs))
+	for i, req := range reqs {
+		input, err := c.abi.Pack(req.method, req.args...)
+		if err != nil {
+			results[i].err = fmt.Errorf("failed to pack %s: %w", req.method, err)
+			continue
+		}
+		elems = append(elems, rpc.BatchElem{
+			Method: "eth_call",
+			Args: []interface{}{
+				map[string]interface{}{"to": c.address, "input": hexutil.Bytes(input)},
+				"latest",
+			},
+			Result: &raw[i],
+		})
+		index = append(index, i)
+	}
+
+	if err := provider.Client().BatchCallContext(ctx, elems); err != nil {
+		for _, i := range index {
+			results[i].err = fmt.Errorf("batch call to %s failed: %w", reqs[i].method, err)
+		}
+		return results
+	}
+
+	for j, elem := range elems {
+		i := index[j]
+		if elem.Error != nil {
+			results[i].err = fmt.Errorf("call to %s failed: %w", reqs[i].method, elem.Error)
+			continue
+		}
+		results[i].out, results[i].err = c.unpack(reqs[i].method, raw[i])
+	}
+	return results
+}
+
+func (c *contract) unpack(method string, res []byte) ([]interface{}, error) {
+	out, err := c.abi.Unpack(method, res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack %s: %w", method, err)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s returned no values", method)
+	}
+	return out, nil
+}
@@ -0,0 +1,133 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// serveChain serves chain over HTTP JSON-RPC and counts the requests, so a
+// batch shows up as a single request.
+func serveChain(t *testing.T, chain *fakeChain, batchLimit int) (*ethclient.Client, *atomic.Int32) {
+	t.Helper()
+	server := rpc.NewServer()
+	if batchLimit > 0 {
+		server.SetBatchLimits(batchLimit, 1<<20)
+	}
+	if err := server.RegisterName("eth", &rpcChain{chain: chain}); err != nil {
+		t.Fatal(err)
+	}
+	var requests atomic.Int32
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		server.ServeHTTP(w, r)
+	}))
+	client, err := ethclient.Dial(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		httpServer.Close()
+		server.Stop()
+	})
+	return client, &requests
+}
+
+func newBatchChain(t *testing.T) *fakeChain {
+	t.Helper()
+	return &fakeChain{
+		abi: loadTestABI(t),
+		results: map[string][]interface{}{
+			"getTotalRewards":   {[]*big.Int{big.NewInt(120), big.NewInt(-5)}},
+			"getVoterVoteCount": {big.NewInt(9)},
+		},
+	}
+}
+
+func TestBatchCallSendsOneRequest(t *testing.T) {
+	chain := newBatchChain(t)
+	client, requests := serveChain(t, chain, 0)
+	c := &contract{client: client, address: testContract, abi: chain.abi}
+
+	results := c.batchCall(context.Background(), []callRequest{
+		{method: "getTotalRewards", args: []interface{}{[]string{"QmA", "QmB"}}},
+		{method: "getVoterVoteCount", args: []interface{}{"QmA"}},
+		{method: "getTotalWins", args: []interface{}{"QmA"}},   // reverts on the node
+		{method: "getVoterVoteCount", args: []interface{}{42}}, // cannot be packed
+	})
+
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d HTTP requests, want one batch", n)
+	}
+	if len(results) != 4 {
+		t.Fatalf("%d results for 4 requests", len(results))
+	}
+	if want := []*big.Int{big.NewInt(120), big.NewInt(-5)}; results[0].err != nil || !reflect.DeepEqual(results[0].out[0], want) {
+		t.Errorf("getTotalRewards = %v, %v", results[0].out, results[0].err)
+	}
+	if results[1].err != nil || results[1].out[0].(*big.Int).Int64() != 9 {
+		t.Errorf("getVoterVoteCount = %v, %v", results[1].out, results[1].err)
+	}
+	// Failed elements only fail their own result.
+	if err := results[2].err; err == nil || !strings.Contains(err.Error(), "call to getTotalWins failed: no result for getTotalWins") {
+		t.Errorf("getTotalWins error = %v", err)
+	}
+	if err := results[3].err; err == nil || !strings.Contains(err.Error(), "failed to pack getVoterVoteCount") {
+		t.Errorf("unpackable request error = %v", err)
+	}
+}
+
+func TestBatchCallRejectedBatch(t *testing.T) {
+	chain := newBatchChain(t)
+	client, _ := serveChain(t, chain, 1)
+	c := &contract{client: client, address: testContract, abi: chain.abi}
+
+	results := c.batchCall(context.Background(), []callRequest{
+		{method: "getVoterVoteCount", args: []interface{}{"QmA"}},
+		{method: "getVoterVoteCount", args: []interface{}{"QmB"}},
+	})
+	for i, res := range results {
+		if res.err == nil {
+			t.Errorf("result %d = %v from a rejected batch", i, res.out)
+		}
+	}
+}
+
+func TestBatchCallFallsBackToSequentialCalls(t *testing.T) {
+	chain := newBatchChain(t)
+	c := &contract{client: chain, address: testContract, abi: chain.abi}
+
+	results := c.batchCall(context.Background(), []callRequest{
+		{method: "getVoterVoteCount", args: []interface{}{"QmA"}},
+		{method: "getTotalWins", args: []interface{}{"QmA"}},
+	})
+	if results[0].err != nil || results[0].out[0].(*big.Int).Int64() != 9 || results[1].err == nil {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestFetchPeerStatsInOneBatch(t *testing.T) {
+	chain := newBatchChain(t)
+	chain.results["getTotalWins"] = []interface{}{big.NewInt(2)}
+	client, requests := serveChain(t, chain, 0)
+	c := &contract{client: client, address: testContract, abi: chain.abi}
+
+	stats := fetchPeerStats(context.Background(), c, []peerIdentity{{PeerID: "QmA"}, {PeerID: "QmB"}})
+
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d HTTP requests for 2 peers, want one batch", n)
+	}
+	if len(stats) != 2 || stats[0].TotalRewards != 120 || stats[1].TotalRewards != -5 ||
+		stats[1].Participation != 9 || stats[1].TotalWins != 2 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
@@ -0,0 +1,130 @@
+package blockchain
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"reflect"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"gswarm-sidecar/internal/processor"
+)
+
+// parseEvent decodes any event declared in the contract ABI. Indexed arguments
+// are recovered from the log topics and non-indexed ones from the log data;
+// both are merged into Data under their ABI argument names. Indexed dynamic
+// values (strings, bytes, arrays) are only available as their keccak256 hash.
+func parseEvent(vLog *types.Log, contractABI *abi.ABI) (processor.ContractEvent, bool) {
+	event := processor.ContractEvent{
+		Timestamp:   time.Now(),
+		BlockHash:   vLog.BlockHash.Hex(),
+		TxHash:      vLog.TxHash.Hex(),
+		Data:        make(map[string]interface{}),
+		BlockNumber: vLog.BlockNumber,
+		LogIndex:    vLog.Index,
+	}
+	if len(vLog.Topics) == 0 {
+		return event, false
+	}
+
+	abiEvent, err := contractABI.EventByID(vLog.Topics[0])
+	if err != nil {
+		log.Printf("[blockchain] Skipping log with unknown event topic %s in tx %s", vLog.Topics[0].Hex(), event.TxHash)
+		return event, false
+	}
+	event.EventType = abiEvent.RawName
+
+	if err := decodeEventArgs(abiEvent, vLog, event.Data); err != nil {
+		log.Printf("[blockchain] Failed to decode %s in tx %s: %v", abiEvent.RawName, event.TxHash, err)
+		return event, false
+	}
+	return event, true
+}
+
+func decodeEventArgs(abiEvent *abi.Event, vLog *types.Log, out map[string]interface{}) error {
+	var indexed abi.Arguments
+	for _, arg := range abiEvent.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+
+	raw := make(map[string]interface{}, len(abiEvent.Inputs))
+	if len(vLog.Topics)-1 != len(indexed) {
+		return fmt.Errorf("expected %d indexed topics, got %d", len(indexed), len(vLog.Topics)-1)
+	}
+	if err := abi.ParseTopicsIntoMap(raw, indexed, vLog.Topics[1:]); err != nil {
+		return fmt.Errorf("failed to parse topics: %w", err)
+	}
+	// The ABI decoder ignores a trailing partial word, and with no data at
+	// all it would leave the non-indexed arguments unset.
+	if len(vLog.Data)%32 != 0 {
+		return fmt.Errorf("data length %d is not a multiple of 32", len(vLog.Data))
+	}
+	if nonIndexed := abiEvent.Inputs.NonIndexed(); len(nonIndexed) > 0 {
+		if err := nonIndexed.UnpackIntoMap(raw, vLog.Data); err != nil {
+			return fmt.Errorf("failed to unpack data: %w", err)
+		}
+	}
+
+	for name, value := range raw {
+		out[name] = normalizeValue(value)
+	}
+	return nil
+}
+
+// normalizeValue converts decoded ABI values into JSON-friendly types. Big
+// integers become decimal strings so no precision is lost in transit.
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case *big.Int:
+		if v == nil {
+			return nil
+		}
+		return v.String()
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case []byte:
+		return hexutil.Encode(v)
+	case string, bool,
+		int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+		return v
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Array:
+		// Fixed-size byte arrays (bytes1..bytes32) are rendered as hex.
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			buf := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(buf), rv)
+			return hexutil.Encode(buf)
+		}
+		fallthrough
+	case reflect.Slice:
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = normalizeValue(rv.Index(i).Interface())
+		}
+		return items
+	case reflect.Struct:
+		fields := make(map[string]interface{}, rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			if rv.Type().Field(i).IsExported() {
+				fields[rv.Type().Field(i).Name] = normalizeValue(rv.Field(i).Interface())
+			}
+		}
+		return fields
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
@@ -0,0 +1,120 @@
+package blockchain
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const taggedEventABI = `[{"type":"event","name":"Tagged","anonymous":false,"inputs":[
+	{"name":"tag","type":"string","indexed":true},
+	{"name":"blob","type":"bytes","indexed":true},
+	{"name":"owner","type":"address","indexed":true},
+	{"name":"note","type":"string","indexed":false}]}]`
+
+func TestParseEventIndexedDynamicTypes(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(taggedEventABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := common.HexToAddress("0x52908400098527886E0F7030069857D2E4169EE7")
+	vLog := contractLog(t, &parsed, 10, "Tagged", "round-7", []byte{1, 2, 3}, owner, "hello")
+
+	event, ok := parseEvent(&vLog, &parsed)
+	if !ok {
+		t.Fatal("event not decoded")
+	}
+	// Indexed strings and bytes only reach the log as their keccak256 hash.
+	want := map[string]interface{}{
+		"tag":   crypto.Keccak256Hash([]byte("round-7")).Hex(),
+		"blob":  crypto.Keccak256Hash([]byte{1, 2, 3}).Hex(),
+		"owner": owner.Hex(),
+		"note":  "hello",
+	}
+	for k, v := range want {
+		if event.Data[k] != v {
+			t.Errorf("%s = %v, want %v", k, event.Data[k], v)
+		}
+	}
+}
+
+func TestParseEventEveryABIEvent(t *testing.T) {
+	contractABI := loadTestABI(t)
+	account := common.HexToAddress("0x52908400098527886E0F7030069857D2E4169EE7")
+	role := [32]byte{0xaa}
+
+	vLog := contractLog(t, contractABI, 5, "WinnerSubmitted", account, "QmPeer", big.NewInt(3), []string{"QmA", "QmB"})
+	event, ok := parseEvent(&vLog, contractABI)
+	if !ok || event.EventType != "WinnerSubmitted" {
+		t.Fatalf("WinnerSubmitted parsed as %+v", event)
+	}
+	winners, _ := event.Data["winners"].([]interface{})
+	if event.Data["roundNumber"] != "3" || len(winners) != 2 || winners[1] != "QmB" {
+		t.Errorf("WinnerSubmitted data = %v", event.Data)
+	}
+
+	vLog = contractLog(t, contractABI, 5, "RoleGranted", role, account, account)
+	event, ok = parseEvent(&vLog, contractABI)
+	if !ok || event.Data["role"] != common.Hash(role).Hex() || event.Data["sender"] != account.Hex() {
+		t.Errorf("RoleGranted parsed as %+v", event)
+	}
+}
+
+func TestParseEventRejectsMalformedLogs(t *testing.T) {
+	contractABI := loadTestABI(t)
+	account := common.HexToAddress("0x52908400098527886E0F7030069857D2E4169EE7")
+	valid := contractLog(t, contractABI, 5, "WinnerSubmitted", account, "QmPeer", big.NewInt(3), []string{"QmA", "QmB"})
+
+	malformed := map[string]func(topics *[]common.Hash, data *[]byte){
+		"no topics":        func(topics *[]common.Hash, _ *[]byte) { *topics = nil },
+		"unknown event":    func(topics *[]common.Hash, _ *[]byte) { (*topics)[0] = common.Hash{1} },
+		"missing topic":    func(topics *[]common.Hash, _ *[]byte) { *topics = (*topics)[:2] },
+		"extra topic":      func(topics *[]common.Hash, _ *[]byte) { *topics = append(*topics, common.Hash{}) },
+		"no data":          func(_ *[]common.Hash, data *[]byte) { *data = nil },
+		"truncated data":   func(_ *[]common.Hash, data *[]byte) { *data = (*data)[:len(*data)/2] },
+		"unaligned data":   func(_ *[]common.Hash, data *[]byte) { *data = (*data)[:len(*data)-1] },
+		"offset past data": func(_ *[]common.Hash, data *[]byte) { (*data)[31] = 0xff },
+		"length past data": func(_ *[]common.Hash, data *[]byte) { copy((*data)[64:96], bytes.Repeat([]byte{0xff}, 32)) },
+	}
+	for name, corrupt := range malformed {
+		vLog := valid
+		vLog.Topics = append([]common.Hash(nil), valid.Topics...)
+		vLog.Data = append([]byte(nil), valid.Data...)
+		corrupt(&vLog.Topics, &vLog.Data)
+		if event, ok := parseEvent(&vLog, contractABI); ok {
+			t.Errorf("%s: decoded %+v", name, event.Data)
+		}
+	}
+}
+
+func TestParseEventSurvivesGarbage(t *testing.T) {
+	contractABI := loadTestABI(t)
+	for name, event := range contractABI.Events {
+		topics := []common.Hash{event.ID}
+		for _, input := range event.Inputs {
+			if input.Indexed {
+				topics = append(topics, common.Hash{0xff, 0xff})
+			}
+		}
+		for size := 0; size <= 8*32; size += 7 {
+			for _, fill := range []byte{0x00, 0x01, 0x7f, 0xff} {
+				vLog := contractLog(t, contractABI, 1, "RoundAdvanced", big.NewInt(1))
+				vLog.Topics = topics
+				vLog.Data = bytes.Repeat([]byte{fill}, size)
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Fatalf("%s with %d bytes of %#x panicked: %v", name, size, fill, r)
+						}
+					}()
+					parseEvent(&vLog, contractABI)
+				}()
+			}
+		}
+	}
+}
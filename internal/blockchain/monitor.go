@@ -17,15 +17,35 @@ import (
 	"gswarm-sidecar/internal/processor"
 )
 
+const (
+	defaultLogBlockRange = 1000
+	maxRangesPerPoll     = 20
+	// defaultConfirmations keeps log ingestion this many blocks behind head,
+	// so logs that a reorg removes are not ingested and checkpointed.
+	defaultConfirmations = 12
+	initialBlockRetry    = 10 * time.Second
+)
+
+// chainClient is the subset of ethclient.Client the monitor uses. It is
+// satisfied by simulated backends as well as live RPC clients.
+type chainClient interface {
+	ethereum.BlockNumberReader
+	ethereum.ContractCaller
+	ethereum.LogFilterer
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
 type Monitor struct {
 	cfg       *config.Config
 	processor *processor.Processor
+	rounds    *roundTracker
 }
 
 func New(cfg *config.Config, processor *processor.Processor) *Monitor {
 	return &Monitor{
 		cfg:       cfg,
 		processor: processor,
+		rounds:    &roundTracker{},
 	}
 }
 
@@ -33,17 +53,21 @@ func (m *Monitor) Start(ctx context.Context) {
 	log.Printf("[blockchain] Monitor Start: initializing connection to RPC %s", m.cfg.Blockchain.RPCURL)
 	client, err := ethclient.Dial(m.cfg.Blockchain.RPCURL)
 	if err != nil {
-		log.Fatalf("[blockchain] Failed to connect to Ethereum RPC: %v", err)
+		log.Printf("[blockchain] Failed to connect to Ethereum RPC: %v", err)
+		m.processor.Status().ReportError(processor.ComponentBlockchain, err)
+		return
 	}
+	defer client.Close()
 
 	log.Printf("[blockchain] Connected to Ethereum RPC at %s", m.cfg.Blockchain.RPCURL)
 	contractAddress := common.HexToAddress(m.cfg.Blockchain.ContractAddress)
 	log.Printf("[blockchain] Parsing contract ABI from config")
 	contractABI, err := abi.JSON(strings.NewReader(m.cfg.Blockchain.ContractABI))
 	if err != nil {
-		log.Fatalf("[blockchain] Failed to parse contract ABI: %v", err)
+		log.Printf("[blockchain] Failed to parse contract ABI: %v", err)
+		m.processor.Status().ReportError(processor.ComponentBlockchain, err)
+		return
 	}
-	defer client.Close()
 
 	const defaultPollIntervalSeconds = 60
 	pollInterval := time.Duration(m.cfg.Blockchain.PollInterval) * time.Second
@@ -52,15 +76,81 @@ func (m *Monitor) Start(ctx context.Context) {
 	}
 
 	log.Printf("[blockchain] Poll interval set to %v", pollInterval)
-	var lastBlock uint64
+	m.processor.Status().Register(processor.ComponentBlockchain, 3*pollInterval)
+	lastBlock, err := m.initialBlock(ctx, client)
+	for err != nil {
+		// Without the head, ingestion would start from genesis.
+		log.Printf("[blockchain] Failed to get current block for initial checkpoint, retrying in %v: %v", initialBlockRetry, err)
+		m.processor.Status().ReportError(processor.ComponentBlockchain, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(initialBlockRetry):
+		}
+		lastBlock, err = m.initialBlock(ctx, client)
+	}
 	log.Printf("[blockchain] Entering pollBlockchain loop")
 	m.pollBlockchain(ctx, client, contractAddress, &contractABI, pollInterval, lastBlock)
 	log.Printf("[blockchain] Exiting pollBlockchain loop")
 }
 
+// initialBlock returns the block after which log ingestion resumes: the
+// persisted checkpoint, the configured start block, or one range behind the
+// confirmed head. It fails when the head is needed but cannot be read.
+func (m *Monitor) initialBlock(ctx context.Context, client chainClient) (uint64, error) {
+	path := checkpointPath(m.cfg.Storage.DataPath)
+	cp, ok, err := loadCheckpoint(path, m.cfg.Blockchain.ContractAddress)
+	if err != nil {
+		log.Printf("[blockchain] Ignoring unreadable checkpoint: %v", err)
+	}
+	if ok {
+		log.Printf("[blockchain] Resuming log ingestion after checkpoint block %d", cp.LastBlock)
+		return cp.LastBlock, nil
+	}
+
+	if m.cfg.Blockchain.StartBlock > 0 {
+		log.Printf("[blockchain] No checkpoint, starting log ingestion at configured block %d", m.cfg.Blockchain.StartBlock)
+		return m.cfg.Blockchain.StartBlock - 1, nil
+	}
+
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+	head = m.confirmedHead(head)
+	blockRange := m.blockRange()
+	if head < blockRange {
+		return 0, nil
+	}
+	log.Printf("[blockchain] No checkpoint, starting log ingestion %d blocks behind confirmed head %d", blockRange, head)
+	return head - blockRange, nil
+}
+
+// confirmedHead returns the newest block with enough confirmations to ingest.
+func (m *Monitor) confirmedHead(head uint64) uint64 {
+	confirmations := uint64(defaultConfirmations)
+	switch c := m.cfg.Blockchain.Confirmations; {
+	case c < 0:
+		confirmations = 0
+	case c > 0:
+		confirmations = uint64(c)
+	}
+	if head < confirmations {
+		return 0
+	}
+	return head - confirmations
+}
+
+func (m *Monitor) blockRange() uint64 {
+	if m.cfg.Blockchain.LogBlockRange > 0 {
+		return uint64(m.cfg.Blockchain.LogBlockRange)
+	}
+	return defaultLogBlockRange
+}
+
 func (m *Monitor) pollBlockchain(
 	ctx context.Context,
-	client *ethclient.Client,
+	client chainClient,
 	contractAddress common.Address,
 	contractABI *abi.ABI,
 	pollInterval time.Duration,
@@ -85,7 +175,7 @@ func (m *Monitor) pollBlockchain(
 
 func (m *Monitor) pollOnce(
 	ctx context.Context,
-	client *ethclient.Client,
+	client chainClient,
 	contractAddress common.Address,
 	contractABI *abi.ABI,
 	lastBlock *uint64,
@@ -94,150 +184,121 @@ func (m *Monitor) pollOnce(
 	currentBlock, err := client.BlockNumber(ctx)
 	if err != nil {
 		log.Printf("[blockchain] Failed to get current block: %v", err)
+		m.processor.Status().ReportError(processor.ComponentBlockchain, err)
 		return
 	}
 
-	peerId := m.cfg.Blockchain.NodePeerID
-	if peerId == "" {
-		log.Printf("[blockchain] No peerId configured, skipping blockchain stats poll")
-		return
-	}
+	events, scannedTo := m.ingestEvents(ctx, client, contractAddress, contractABI, *lastBlock, m.confirmedHead(currentBlock))
 
-	// getVoterVoteCount(peerId)
-	var participation uint64
-	voteCountOut, err := contractABI.Pack("getVoterVoteCount", peerId)
-	if err != nil {
-		log.Printf("[blockchain] Failed to pack getVoterVoteCount: %v", err)
-	} else {
-		res, callErr := client.CallContract(ctx, ethereum.CallMsg{
-			To:   &contractAddress,
-			Data: voteCountOut,
-		}, nil)
-		if callErr != nil {
-			log.Printf("[blockchain] Call to getVoterVoteCount failed: %v", callErr)
-		} else {
-			out, unpackErr := contractABI.Unpack("getVoterVoteCount", res)
-			if unpackErr != nil {
-				log.Printf("[blockchain] Failed to unpack getVoterVoteCount: %v", unpackErr)
-			} else if len(out) > 0 {
-				if v, ok := out[0].(*big.Int); ok {
-					participation = v.Uint64()
-				}
-			}
-		}
+	metrics := &processor.BlockchainMetrics{
+		BlockNumber: currentBlock,
 	}
 
-	// getTotalRewards([peerId])
-	var totalRewards int64
-	rewardsOut, err := contractABI.Pack("getTotalRewards", []string{peerId})
-	if err != nil {
-		log.Printf("[blockchain] Failed to pack getTotalRewards: %v", err)
-	} else {
-		res, callErr := client.CallContract(ctx, ethereum.CallMsg{
-			To:   &contractAddress,
-			Data: rewardsOut,
-		}, nil)
-		if callErr != nil {
-			log.Printf("[blockchain] Call to getTotalRewards failed: %v", callErr)
-		} else {
-			out, unpackErr := contractABI.Unpack("getTotalRewards", res)
-			if unpackErr != nil {
-				log.Printf("[blockchain] Failed to unpack getTotalRewards: %v", unpackErr)
-			} else if len(out) > 0 {
-				if arr, ok := out[0].([]*big.Int); ok && len(arr) > 0 {
-					totalRewards = arr[0].Int64()
-				}
-			}
-		}
-	}
+	c := &contract{client: client, address: contractAddress, abi: contractABI}
 
-	// getTotalWins(peerId)
-	var totalWins uint64
-	winsOut, err := contractABI.Pack("getTotalWins", peerId)
-	if err != nil {
-		log.Printf("[blockchain] Failed to pack getTotalWins: %v", err)
+	peerIDs, eoas := m.configuredPeers()
+	var peers []peerIdentity
+	if len(peerIDs) == 0 && len(eoas) == 0 {
+		log.Printf("[blockchain] No peerId or EOA configured, skipping blockchain stats poll")
 	} else {
-		res, callErr := client.CallContract(ctx, ethereum.CallMsg{
-			To:   &contractAddress,
-			Data: winsOut,
-		}, nil)
-		if callErr != nil {
-			log.Printf("[blockchain] Call to getTotalWins failed: %v", callErr)
-		} else {
-			out, unpackErr := contractABI.Unpack("getTotalWins", res)
-			if unpackErr != nil {
-				log.Printf("[blockchain] Failed to unpack getTotalWins: %v", unpackErr)
-			} else if len(out) > 0 {
-				if v, ok := out[0].(*big.Int); ok {
-					totalWins = v.Uint64()
-				}
-			}
+		peers = resolvePeers(ctx, c, peerIDs, eoas)
+		metrics.Peers = fetchPeerStats(ctx, c, peers)
+		for _, p := range metrics.Peers {
+			metrics.Participation += p.Participation
+			metrics.TotalRewards += p.TotalRewards
+			metrics.TotalWins += p.TotalWins
 		}
 	}
 
-	metrics := &processor.BlockchainMetrics{
-		Participation: participation,
-		TotalRewards:  totalRewards,
-		TotalWins:     totalWins,
-		BlockNumber:   currentBlock,
-	}
+	m.rounds.setPeers(peers)
+	roundEvents := m.rounds.update(ctx, c, events, metrics)
+	metrics.ContractEvents = append(events, roundEvents...)
 
-	log.Printf("[blockchain] Blockchain stats: participation=%d, total_rewards=%d, total_wins=%d, block=%d", participation, totalRewards, totalWins, currentBlock)
+	log.Printf("[blockchain] Blockchain stats: peers=%d, participation=%d, total_rewards=%d, total_wins=%d, block=%d, round=%d, stage=%d, events=%d",
+		len(metrics.Peers), metrics.Participation, metrics.TotalRewards, metrics.TotalWins, currentBlock,
+		metrics.CurrentRound, metrics.CurrentStage, len(metrics.ContractEvents))
 	if err := m.processor.ProcessBlockchain(ctx, metrics); err != nil {
 		log.Printf("[blockchain] Failed to process blockchain metrics: %v", err)
+		return
 	}
 
-	*lastBlock = currentBlock
+	// Only advance the round tracker and checkpoint once the events have
+	// been handed off.
+	m.rounds.commit()
+	if scannedTo > *lastBlock {
+		*lastBlock = scannedTo
+		cp := checkpoint{ContractAddress: m.cfg.Blockchain.ContractAddress, LastBlock: scannedTo}
+		if err := saveCheckpoint(checkpointPath(m.cfg.Storage.DataPath), cp); err != nil {
+			log.Printf("[blockchain] Failed to save checkpoint: %v", err)
+		}
+	}
 }
 
-func parseEvent(vLog *types.Log, contractABI *abi.ABI) (processor.ContractEvent, bool) {
-	// TODO: Add case for vote-related events here, e.g., VoteSubmitted, based on the contract ABI. Need details on event name and parameters.
-	event := processor.ContractEvent{
-		Timestamp: time.Now(),
-		BlockHash: vLog.BlockHash.Hex(),
-		TxHash:    vLog.TxHash.Hex(),
-		Data:      make(map[string]interface{}),
-	}
-	switch vLog.Topics[0] {
-	case contractABI.Events["RewardSubmitted"].ID:
-		parsed, err := contractABI.Unpack("RewardSubmitted", vLog.Data)
-		if err != nil {
-			log.Printf("Failed to unpack RewardSubmitted: %v", err)
-			return event, false
+// ingestEvents fetches contract logs in (fromBlock, toBlock] in chunks of the
+// configured block range. It returns the decoded events and the last block
+// that was fully scanned, which stops short of toBlock on error or when the
+// per-poll range limit is reached.
+func (m *Monitor) ingestEvents(
+	ctx context.Context,
+	client chainClient,
+	contractAddress common.Address,
+	contractABI *abi.ABI,
+	fromBlock uint64,
+	toBlock uint64,
+) ([]processor.ContractEvent, uint64) {
+	events := []processor.ContractEvent{}
+	scannedTo := fromBlock
+	blockRange := m.blockRange()
+	headers := make(map[uint64]time.Time)
+
+	for i := 0; i < maxRangesPerPoll && scannedTo < toBlock; i++ {
+		start := scannedTo + 1
+		end := start + blockRange - 1
+		if end > toBlock {
+			end = toBlock
 		}
-		event.EventType = "RewardSubmitted"
-		event.Data["account"] = parsed[0].(common.Address).Hex()
-		event.Data["roundNumber"] = parsed[1].(*big.Int).String()
-		event.Data["stageNumber"] = parsed[2].(*big.Int).String()
-		event.Data["reward"] = parsed[3].(*big.Int).String()
-		event.Data["peerId"] = parsed[4].(string)
-		return event, true
-	case contractABI.Events["CumulativeRewardsUpdated"].ID:
-		parsed, err := contractABI.Unpack("CumulativeRewardsUpdated", vLog.Data)
+
+		logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(start),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: []common.Address{contractAddress},
+		})
 		if err != nil {
-			log.Printf("Failed to unpack CumulativeRewardsUpdated: %v", err)
-			return event, false
+			log.Printf("[blockchain] Failed to filter logs for blocks %d-%d: %v", start, end, err)
+			break
 		}
-		event.EventType = "CumulativeRewardsUpdated"
-		event.Data["account"] = parsed[0].(common.Address).Hex()
-		event.Data["peerId"] = parsed[1].(string)
-		event.Data["totalRewards"] = parsed[2].(*big.Int).String()
-		return event, true
-	case contractABI.Events["VoteSubmitted"].ID:
-		parsed, err := contractABI.Unpack("VoteSubmitted", vLog.Data)
-		if err != nil {
-			log.Printf("Failed to unpack VoteSubmitted: %v", err)
-			return event, false
+
+		for j := range logs {
+			vLog := &logs[j]
+			if vLog.Removed {
+				continue
+			}
+			event, ok := parseEvent(vLog, contractABI)
+			if !ok {
+				continue
+			}
+			event.Timestamp = m.blockTime(ctx, client, vLog.BlockNumber, headers)
+			events = append(events, event)
 		}
-		event.EventType = "VoteSubmitted"
-		event.Data["account"] = parsed[0].(common.Address).Hex()
-		event.Data["peerId"] = parsed[1].(string)
-		event.Data["roundNumber"] = parsed[2].(*big.Int).String()
-		event.Data["stageNumber"] = parsed[3].(*big.Int).String()
-		event.Data["voteType"] = parsed[4].(string)
-		event.Data["voteValue"] = parsed[5].(bool)
-		return event, true
-	default:
-		return event, false
+		log.Printf("[blockchain] Scanned blocks %d-%d: %d logs", start, end, len(logs))
+		scannedTo = end
+	}
+
+	return events, scannedTo
+}
+
+// blockTime returns the timestamp of block number, caching headers per poll.
+// It falls back to the current time when the header cannot be fetched.
+func (m *Monitor) blockTime(ctx context.Context, client chainClient, number uint64, cache map[uint64]time.Time) time.Time {
+	if ts, ok := cache[number]; ok {
+		return ts
+	}
+	header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		log.Printf("[blockchain] Failed to get header for block %d: %v", number, err)
+		return time.Now()
 	}
+	ts := time.Unix(int64(header.Time), 0).UTC()
+	cache[number] = ts
+	return ts
 }
@@ -0,0 +1,351 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"gswarm-sidecar/internal/config"
+	"gswarm-sidecar/internal/processor"
+)
+
+var testContract = common.HexToAddress("0xFaD7C5e93f28257429569B854151A1B8DCD404c2")
+
+func loadTestABI(t *testing.T) *abi.ABI {
+	t.Helper()
+	data, err := os.ReadFile("../../configs/contract.abi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := abi.JSON(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &parsed
+}
+
+// fakeChain is an in-memory chainClient. Contract calls are answered from
+// results, keyed by method name.
+type fakeChain struct {
+	abi     *abi.ABI
+	head    uint64
+	headErr error
+	logs    []types.Log
+	results map[string][]interface{}
+	queries []ethereum.FilterQuery
+}
+
+func (c *fakeChain) BlockNumber(context.Context) (uint64, error) {
+	return c.head, c.headErr
+}
+
+func (c *fakeChain) CallContract(_ context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	for name, method := range c.abi.Methods {
+		if !bytes.HasPrefix(msg.Data, method.ID) {
+			continue
+		}
+		values, ok := c.results[name]
+		if !ok {
+			return nil, fmt.Errorf("no result for %s", name)
+		}
+		return method.Outputs.Pack(values...)
+	}
+	return nil, errors.New("unknown method")
+}
+
+func (c *fakeChain) FilterLogs(_ context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	c.queries = append(c.queries, q)
+	var out []types.Log
+	for _, l := range c.logs {
+		if l.BlockNumber >= q.FromBlock.Uint64() && l.BlockNumber <= q.ToBlock.Uint64() {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+func (c *fakeChain) SubscribeFilterLogs(context.Context, ethereum.FilterQuery, chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *fakeChain) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: number, Time: number.Uint64(), Difficulty: new(big.Int)}, nil
+}
+
+// rpcChain serves a fakeChain as the eth_ JSON-RPC methods the monitor uses.
+type rpcChain struct {
+	chain *fakeChain
+}
+
+type rpcFilter struct {
+	FromBlock hexutil.Uint64   `json:"fromBlock"`
+	ToBlock   hexutil.Uint64   `json:"toBlock"`
+	Address   []common.Address `json:"address"`
+}
+
+type rpcCallArgs struct {
+	To    *common.Address `json:"to"`
+	Input hexutil.Bytes   `json:"input"`
+}
+
+func (s *rpcChain) BlockNumber(ctx context.Context) (hexutil.Uint64, error) {
+	head, err := s.chain.BlockNumber(ctx)
+	return hexutil.Uint64(head), err
+}
+
+func (s *rpcChain) GetLogs(ctx context.Context, f rpcFilter) ([]types.Log, error) {
+	return s.chain.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(uint64(f.FromBlock)),
+		ToBlock:   new(big.Int).SetUint64(uint64(f.ToBlock)),
+		Addresses: f.Address,
+	})
+}
+
+func (s *rpcChain) Call(ctx context.Context, args rpcCallArgs, _ string) (hexutil.Bytes, error) {
+	return s.chain.CallContract(ctx, ethereum.CallMsg{To: args.To, Data: args.Input}, nil)
+}
+
+func (s *rpcChain) GetBlockByNumber(ctx context.Context, number hexutil.Uint64, _ bool) (*types.Header, error) {
+	return s.chain.HeaderByNumber(ctx, new(big.Int).SetUint64(uint64(number)))
+}
+
+// dialChain returns an ethclient.Client talking JSON-RPC to chain through an
+// in-process server, so requests and responses go through the same encoding
+// as against a node.
+func dialChain(t *testing.T, chain *fakeChain) *ethclient.Client {
+	t.Helper()
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &rpcChain{chain: chain}); err != nil {
+		t.Fatal(err)
+	}
+	client := ethclient.NewClient(rpc.DialInProc(server))
+	t.Cleanup(func() {
+		client.Close()
+		server.Stop()
+	})
+	return client
+}
+
+// contractLog builds the log the contract emits for event name with args in
+// ABI order, indexed ones as topics.
+func contractLog(t *testing.T, contractABI *abi.ABI, block uint64, name string, args ...interface{}) types.Log {
+	t.Helper()
+	event := contractABI.Events[name]
+	topics := []common.Hash{event.ID}
+	var data []interface{}
+	for i, input := range event.Inputs {
+		if !input.Indexed {
+			data = append(data, args[i])
+			continue
+		}
+		hashes, err := abi.MakeTopics([]interface{}{args[i]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, hashes[0][0])
+	}
+	packed, err := event.Inputs.NonIndexed().Pack(data...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types.Log{
+		Address:     testContract,
+		Topics:      topics,
+		Data:        packed,
+		BlockNumber: block,
+		TxHash:      common.BigToHash(new(big.Int).SetUint64(block)),
+	}
+}
+
+func newTestMonitor(t *testing.T) *Monitor {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Blockchain.ContractAddress = testContract.Hex()
+	cfg.Storage.DataPath = t.TempDir()
+	return New(cfg, nil)
+}
+
+func TestInitialBlockFailsWithoutHead(t *testing.T) {
+	m := newTestMonitor(t)
+	chain := &fakeChain{headErr: errors.New("rpc down")}
+
+	if _, err := m.initialBlock(context.Background(), chain); err == nil {
+		t.Fatal("initialBlock returned a block without knowing the head")
+	}
+}
+
+func TestInitialBlockStartsBehindConfirmedHead(t *testing.T) {
+	m := newTestMonitor(t)
+	chain := &fakeChain{head: 5000}
+
+	got, err := m.initialBlock(context.Background(), chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := uint64(5000 - defaultConfirmations - defaultLogBlockRange); got != want {
+		t.Errorf("initial block = %d, want %d", got, want)
+	}
+}
+
+func TestInitialBlockResumesFromCheckpoint(t *testing.T) {
+	m := newTestMonitor(t)
+	cp := checkpoint{ContractAddress: m.cfg.Blockchain.ContractAddress, LastBlock: 1234}
+	if err := saveCheckpoint(checkpointPath(m.cfg.Storage.DataPath), cp); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := m.initialBlock(context.Background(), &fakeChain{headErr: errors.New("rpc down")})
+	if err != nil || got != 1234 {
+		t.Errorf("initial block = %d, %v; want 1234 from the checkpoint", got, err)
+	}
+}
+
+func TestConfirmedHead(t *testing.T) {
+	m := newTestMonitor(t)
+	for _, tc := range []struct {
+		confirmations int
+		head, want    uint64
+	}{
+		{0, 100, 100 - defaultConfirmations},
+		{-1, 100, 100},
+		{5, 100, 95},
+		{5, 3, 0},
+	} {
+		m.cfg.Blockchain.Confirmations = tc.confirmations
+		if got := m.confirmedHead(tc.head); got != tc.want {
+			t.Errorf("confirmations %d: confirmedHead(%d) = %d, want %d", tc.confirmations, tc.head, got, tc.want)
+		}
+	}
+}
+
+func TestIngestEventsScansInRanges(t *testing.T) {
+	contractABI := loadTestABI(t)
+	m := newTestMonitor(t)
+	m.cfg.Blockchain.LogBlockRange = 100
+	chain := &fakeChain{
+		abi:  contractABI,
+		head: 400,
+		logs: []types.Log{
+			contractLog(t, contractABI, 150, "StageAdvanced", big.NewInt(7), big.NewInt(1)),
+			contractLog(t, contractABI, 395, "StageAdvanced", big.NewInt(7), big.NewInt(2)), // not yet confirmed
+		},
+	}
+
+	events, scannedTo := m.ingestEvents(context.Background(), chain, testContract, contractABI, 0, m.confirmedHead(chain.head))
+
+	if want := chain.head - defaultConfirmations; scannedTo != want {
+		t.Errorf("scanned to %d, want %d", scannedTo, want)
+	}
+	if len(chain.queries) != 4 {
+		t.Errorf("%d eth_getLogs queries, want 4 ranges of 100 blocks", len(chain.queries))
+	}
+	for _, q := range chain.queries {
+		if q.ToBlock.Uint64() > scannedTo {
+			t.Errorf("queried up to block %d past the confirmed head", q.ToBlock.Uint64())
+		}
+	}
+	if len(events) != 1 || events[0].EventType != "StageAdvanced" {
+		t.Fatalf("events = %+v, want the confirmed StageAdvanced only", events)
+	}
+	if events[0].Data["roundNumber"] != "7" || events[0].Data["newStage"] != "1" {
+		t.Errorf("decoded data = %v", events[0].Data)
+	}
+}
+
+func TestIngestEventsResumesOverRPC(t *testing.T) {
+	contractABI := loadTestABI(t)
+	account := common.HexToAddress("0x52908400098527886E0F7030069857D2E4169EE7")
+	chain := &fakeChain{
+		abi:  contractABI,
+		head: 250,
+		logs: []types.Log{
+			contractLog(t, contractABI, 50, "RoundAdvanced", big.NewInt(7)),
+			contractLog(t, contractABI, 51, "StageAdvanced", big.NewInt(7), big.NewInt(1)),
+			contractLog(t, contractABI, 100, "RewardSubmitted", account, big.NewInt(7), big.NewInt(1), big.NewInt(-3), "QmPeer"),
+			contractLog(t, contractABI, 245, "StageAdvanced", big.NewInt(7), big.NewInt(2)),
+			contractLog(t, contractABI, 246, "RoundAdvanced", big.NewInt(8)), // 4 confirmations only
+		},
+	}
+	client := dialChain(t, chain)
+
+	newMonitor := func(dataPath string) *Monitor {
+		m := newTestMonitor(t)
+		m.cfg.Storage.DataPath = dataPath
+		m.cfg.Blockchain.StartBlock = 1
+		m.cfg.Blockchain.LogBlockRange = 50
+		m.cfg.Blockchain.Confirmations = 5
+		return m
+	}
+	dataPath := t.TempDir()
+	m := newMonitor(dataPath)
+	ctx := context.Background()
+
+	poll := func(m *Monitor) ([]processor.ContractEvent, uint64) {
+		t.Helper()
+		from, err := m.initialBlock(ctx, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		head, err := client.BlockNumber(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, scannedTo := m.ingestEvents(ctx, client, testContract, contractABI, from, m.confirmedHead(head))
+		cp := checkpoint{ContractAddress: m.cfg.Blockchain.ContractAddress, LastBlock: scannedTo}
+		if err := saveCheckpoint(checkpointPath(m.cfg.Storage.DataPath), cp); err != nil {
+			t.Fatal(err)
+		}
+		return events, scannedTo
+	}
+
+	events, scannedTo := poll(m)
+	if scannedTo != 245 {
+		t.Errorf("scanned to %d, want the confirmed head 245", scannedTo)
+	}
+	var ranges []string
+	for _, q := range chain.queries {
+		ranges = append(ranges, fmt.Sprintf("%d-%d", q.FromBlock, q.ToBlock))
+	}
+	if got := strings.Join(ranges, " "); got != "1-50 51-100 101-150 151-200 201-245" {
+		t.Errorf("eth_getLogs ranges %s", got)
+	}
+	if len(events) != 4 {
+		t.Fatalf("%d events, want the 4 confirmed ones", len(events))
+	}
+	reward := events[2]
+	if reward.EventType != "RewardSubmitted" || reward.BlockNumber != 100 || !reward.Timestamp.Equal(time.Unix(100, 0)) {
+		t.Errorf("reward event = %+v", reward)
+	}
+	want := map[string]interface{}{"account": account.Hex(), "roundNumber": "7", "stageNumber": "1", "reward": "-3", "peerId": "QmPeer"}
+	if !reflect.DeepEqual(reward.Data, want) {
+		t.Errorf("reward data = %v, want %v", reward.Data, want)
+	}
+
+	// Restart: the new monitor resumes after the checkpoint, so nothing is
+	// replayed and the block that was not yet confirmed is not skipped.
+	chain.head = 300
+	chain.logs = append(chain.logs, contractLog(t, contractABI, 290, "StageAdvanced", big.NewInt(8), big.NewInt(0)))
+	chain.queries = nil
+	events, scannedTo = poll(newMonitor(dataPath))
+	if scannedTo != 295 || len(chain.queries) != 1 || chain.queries[0].FromBlock.Uint64() != 246 {
+		t.Errorf("resumed scan to %d with queries %v", scannedTo, chain.queries)
+	}
+	if len(events) != 2 || events[0].BlockNumber != 246 || events[1].BlockNumber != 290 {
+		t.Errorf("resumed events = %+v, want blocks 246 and 290", events)
+	}
+}
@@ -0,0 +1,164 @@
+package blockchain
+
+import (
+	"context"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"gswarm-sidecar/internal/processor"
+)
+
+// peerIdentity pairs an RL-Swarm peer ID with the EOA it is registered under.
+// EOA is the zero address when the mapping could not be resolved.
+type peerIdentity struct {
+	PeerID string
+	EOA    common.Address
+}
+
+// configuredPeers merges the single and list forms of the peer ID and EOA
+// settings, dropping duplicates and malformed addresses.
+func (m *Monitor) configuredPeers() (peerIDs []string, eoas []common.Address) {
+	seenPeers := make(map[string]bool)
+	for _, id := range append([]string{m.cfg.Blockchain.NodePeerID}, m.cfg.Blockchain.NodePeerIDs...) {
+		if id != "" && !seenPeers[id] {
+			seenPeers[id] = true
+			peerIDs = append(peerIDs, id)
+		}
+	}
+
+	seenEOAs := make(map[common.Address]bool)
+	for _, raw := range append([]string{m.cfg.Blockchain.NodeEOA}, m.cfg.Blockchain.NodeEOAs...) {
+		if raw == "" {
+			continue
+		}
+		if !common.IsHexAddress(raw) {
+			log.Printf("[blockchain] Ignoring invalid EOA %q", raw)
+			continue
+		}
+		addr := common.HexToAddress(raw)
+		if !seenEOAs[addr] {
+			seenEOAs[addr] = true
+			eoas = append(eoas, addr)
+		}
+	}
+	return peerIDs, eoas
+}
+
+// resolvePeers completes the EOA <-> peer ID mapping on-chain: configured EOAs
+// are expanded to all their registered peers via getPeerId, and configured
+// peer IDs are matched to their EOA via getEoa. Both lookups are batched.
+func resolvePeers(ctx context.Context, c *contract, peerIDs []string, eoas []common.Address) []peerIdentity {
+	var reqs []callRequest
+	if len(eoas) > 0 {
+		reqs = append(reqs, callRequest{method: "getPeerId", args: []interface{}{eoas}})
+	}
+	if len(peerIDs) > 0 {
+		reqs = append(reqs, callRequest{method: "getEoa", args: []interface{}{peerIDs}})
+	}
+	results := c.batchCall(ctx, reqs)
+
+	peers := make([]peerIdentity, 0, len(peerIDs))
+	index := make(map[string]int)
+	add := func(id string, eoa common.Address) {
+		if i, ok := index[id]; ok {
+			if peers[i].EOA == (common.Address{}) {
+				peers[i].EOA = eoa
+			}
+			return
+		}
+		index[id] = len(peers)
+		peers = append(peers, peerIdentity{PeerID: id, EOA: eoa})
+	}
+
+	next := 0
+	if len(eoas) > 0 {
+		res := results[next]
+		next++
+		if res.err != nil {
+			log.Printf("[blockchain] Failed to resolve peer IDs for EOAs: %v", res.err)
+		} else if lists, ok := res.out[0].([][]string); ok {
+			for i, ids := range lists {
+				if i >= len(eoas) {
+					break
+				}
+				if len(ids) == 0 {
+					log.Printf("[blockchain] EOA %s has no registered peers", eoas[i].Hex())
+				}
+				for _, id := range ids {
+					add(id, eoas[i])
+				}
+			}
+		}
+	}
+
+	if len(peerIDs) > 0 {
+		res := results[next]
+		var resolved []common.Address
+		if res.err != nil {
+			log.Printf("[blockchain] Failed to resolve EOAs for peer IDs: %v", res.err)
+		} else {
+			resolved, _ = res.out[0].([]common.Address)
+		}
+		for i, id := range peerIDs {
+			var eoa common.Address
+			if i < len(resolved) {
+				eoa = resolved[i]
+			}
+			add(id, eoa)
+		}
+	}
+	return peers
+}
+
+// fetchPeerStats queries participation, rewards and wins for every peer. The
+// rewards for all peers come from one getTotalRewards call and the per-peer
+// counters are sent together in the same batch.
+func fetchPeerStats(ctx context.Context, c *contract, peers []peerIdentity) []processor.PeerStats {
+	stats := make([]processor.PeerStats, len(peers))
+	peerIDs := make([]string, len(peers))
+	for i, p := range peers {
+		peerIDs[i] = p.PeerID
+		stats[i] = processor.PeerStats{PeerID: p.PeerID}
+		if p.EOA != (common.Address{}) {
+			stats[i].EOA = p.EOA.Hex()
+		}
+	}
+	if len(peers) == 0 {
+		return stats
+	}
+
+	reqs := []callRequest{{method: "getTotalRewards", args: []interface{}{peerIDs}}}
+	for _, id := range peerIDs {
+		reqs = append(reqs,
+			callRequest{method: "getVoterVoteCount", args: []interface{}{id}},
+			callRequest{method: "getTotalWins", args: []interface{}{id}},
+		)
+	}
+	results := c.batchCall(ctx, reqs)
+
+	if res := results[0]; res.err != nil {
+		log.Printf("[blockchain] %v", res.err)
+	} else if arr, ok := res.out[0].([]*big.Int); ok {
+		for i := range stats {
+			if i < len(arr) {
+				stats[i].TotalRewards = arr[i].Int64()
+			}
+		}
+	}
+
+	for i := range stats {
+		if res := results[1+2*i]; res.err != nil {
+			log.Printf("[blockchain] %v", res.err)
+		} else if v, ok := res.out[0].(*big.Int); ok {
+			stats[i].Participation = v.Uint64()
+		}
+		if res := results[2+2*i]; res.err != nil {
+			log.Printf("[blockchain] %v", res.err)
+		} else if v, ok := res.out[0].(*big.Int); ok {
+			stats[i].TotalWins = v.Uint64()
+		}
+	}
+	return stats
+}
@@ -0,0 +1,281 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"gswarm-sidecar/internal/processor"
+)
+
+const maxRoundHistory = 50
+
+// stagePos identifies a single stage of a swarm round.
+type stagePos struct {
+	round uint64
+	stage uint64
+}
+
+func (p stagePos) after(o stagePos) bool {
+	return p.round > o.round || (p.round == o.round && p.stage > o.stage)
+}
+
+// roundTracker follows the contract's round/stage progression and records,
+// for every stage that closes while the sidecar is running, whether each
+// configured peer submitted a reward for it.
+type roundTracker struct {
+	peers      []peerIdentity
+	stageCount uint64
+	// state is what has been published; pending is what update reached since,
+	// kept until commit so a failed publish closes the same stages again.
+	state   roundState
+	pending roundState
+}
+
+type roundState struct {
+	current stagePos
+	known   bool
+	history []processor.RoundStageStatus
+}
+
+// setPeers replaces the peers whose submissions are checked.
+func (t *roundTracker) setPeers(peers []peerIdentity) {
+	t.peers = peers
+}
+
+// update advances the tracker using RoundAdvanced/StageAdvanced events seen in
+// this poll followed by the contract's currentRound/currentStage, closing every
+// stage passed on the way. It returns the stage summary and missed round
+// events to publish, and fills in the round fields of metrics.
+//
+// The first update only records the contract's current stage: events from
+// the initial backfill describe stages that closed before the sidecar started.
+// Nothing is kept until commit is called.
+func (t *roundTracker) update(
+	ctx context.Context,
+	c *contract,
+	events []processor.ContractEvent,
+	metrics *processor.BlockchainMetrics,
+) []processor.ContractEvent {
+	if t.stageCount == 0 {
+		if out, err := c.call(ctx, "stageCount"); err != nil {
+			log.Printf("[blockchain] %v", err)
+		} else if v, ok := out[0].(*big.Int); ok {
+			t.stageCount = v.Uint64()
+		}
+	}
+
+	s := roundState{
+		current: t.state.current,
+		known:   t.state.known,
+		history: append([]processor.RoundStageStatus(nil), t.state.history...),
+	}
+	var out []processor.ContractEvent
+	if s.known {
+		positions := make([]stagePos, 0, len(events)+1)
+		for _, ev := range events {
+			switch ev.EventType {
+			case "RoundAdvanced":
+				if round, ok := uintField(ev.Data, "newRoundNumber"); ok {
+					positions = append(positions, stagePos{round: round})
+				}
+			case "StageAdvanced":
+				round, okRound := uintField(ev.Data, "roundNumber")
+				stage, okStage := uintField(ev.Data, "newStage")
+				if okRound && okStage {
+					positions = append(positions, stagePos{round: round, stage: stage})
+				}
+			}
+		}
+		if pos, err := t.fetchCurrent(ctx, c); err != nil {
+			log.Printf("[blockchain] Failed to read current round/stage: %v", err)
+		} else {
+			positions = append(positions, pos)
+		}
+
+		for _, pos := range positions {
+			for pos.after(s.current) {
+				events, statuses := t.closeStage(ctx, c, s.current)
+				out = append(out, events...)
+				s.history = append(s.history, statuses...)
+				s.current = t.next(s.current, pos)
+			}
+		}
+		if limit := maxRoundHistory * len(t.peers); len(s.history) > limit {
+			s.history = s.history[len(s.history)-limit:]
+		}
+	} else if pos, err := t.fetchCurrent(ctx, c); err != nil {
+		log.Printf("[blockchain] Failed to read current round/stage: %v", err)
+	} else {
+		s.current, s.known = pos, true
+		log.Printf("[blockchain] Tracking from round %d stage %d", pos.round, pos.stage)
+	}
+
+	if s.known {
+		metrics.CurrentRound = s.current.round
+		metrics.CurrentStage = s.current.stage
+	}
+	metrics.StageCount = t.stageCount
+	metrics.RoundHistory = append([]processor.RoundStageStatus(nil), s.history...)
+	t.pending = s
+	return out
+}
+
+// commit keeps the state reached by the last update, once its events have
+// been published.
+func (t *roundTracker) commit() {
+	t.state = t.pending
+}
+
+// next returns the stage following cur on the way to target. Without a known
+// stage count, or across a gap of more than maxRoundHistory rounds, it jumps
+// straight to target.
+func (t *roundTracker) next(cur, target stagePos) stagePos {
+	if t.stageCount == 0 || target.round-cur.round > maxRoundHistory {
+		return target
+	}
+	n := stagePos{round: cur.round, stage: cur.stage + 1}
+	if n.stage >= t.stageCount {
+		n = stagePos{round: cur.round + 1}
+	}
+	if n.after(target) {
+		return target
+	}
+	return n
+}
+
+func (t *roundTracker) fetchCurrent(ctx context.Context, c *contract) (stagePos, error) {
+	var pos stagePos
+	out, err := c.call(ctx, "currentRound")
+	if err != nil {
+		return pos, err
+	}
+	round, ok := out[0].(*big.Int)
+	if !ok {
+		return pos, fmt.Errorf("unexpected currentRound type %T", out[0])
+	}
+	out, err = c.call(ctx, "currentStage")
+	if err != nil {
+		return pos, err
+	}
+	stage, ok := out[0].(*big.Int)
+	if !ok {
+		return pos, fmt.Errorf("unexpected currentStage type %T", out[0])
+	}
+	return stagePos{round: round.Uint64(), stage: stage.Uint64()}, nil
+}
+
+// closeStage checks each peer's submission for a stage that just ended and
+// returns the events to publish and the statuses to add to the history.
+func (t *roundTracker) closeStage(ctx context.Context, c *contract, pos stagePos) ([]processor.ContractEvent, []processor.RoundStageStatus) {
+	if len(t.peers) == 0 {
+		return nil, nil
+	}
+
+	round := new(big.Int).SetUint64(pos.round)
+	stage := new(big.Int).SetUint64(pos.stage)
+	reqs := make([]callRequest, len(t.peers))
+	for i, p := range t.peers {
+		reqs[i] = callRequest{method: "hasSubmittedRoundStageReward", args: []interface{}{round, stage, p.PeerID}}
+	}
+	submitted := c.batchCall(ctx, reqs)
+
+	// Look up rewards for every submitting peer with a known EOA in one call.
+	var accounts []common.Address
+	for i, p := range t.peers {
+		if ok, _ := firstBool(submitted[i]); ok && p.EOA != (common.Address{}) {
+			accounts = append(accounts, p.EOA)
+		}
+	}
+	rewards := make(map[common.Address]string)
+	if len(accounts) > 0 {
+		out, err := c.call(ctx, "getRoundStageReward", round, stage, accounts)
+		if err != nil {
+			log.Printf("[blockchain] %v", err)
+		} else if values, ok := out[0].([]*big.Int); ok {
+			for i, v := range values {
+				if i < len(accounts) {
+					rewards[accounts[i]] = v.String()
+				}
+			}
+		}
+	}
+
+	closedAt := time.Now().UTC()
+	roundStr := strconv.FormatUint(pos.round, 10)
+	stageStr := strconv.FormatUint(pos.stage, 10)
+	var events []processor.ContractEvent
+	var statuses []processor.RoundStageStatus
+	for i, p := range t.peers {
+		ok, err := firstBool(submitted[i])
+		if err != nil {
+			log.Printf("[blockchain] Could not check submission of %s for round %d stage %d: %v", p.PeerID, pos.round, pos.stage, err)
+			continue
+		}
+
+		status := processor.RoundStageStatus{
+			RoundNumber: pos.round,
+			StageNumber: pos.stage,
+			PeerID:      p.PeerID,
+			Submitted:   ok,
+			Reward:      rewards[p.EOA],
+			ClosedAt:    closedAt,
+		}
+		statuses = append(statuses, status)
+
+		data := map[string]interface{}{
+			"roundNumber": roundStr,
+			"stageNumber": stageStr,
+			"peerId":      p.PeerID,
+			"submitted":   status.Submitted,
+		}
+		if status.Reward != "" {
+			data["reward"] = status.Reward
+		}
+		events = append(events, processor.ContractEvent{
+			EventType: "StageClosed",
+			Timestamp: closedAt,
+			Data:      data,
+		})
+
+		if !status.Submitted {
+			log.Printf("[blockchain] Peer %s missed round %d stage %d", p.PeerID, pos.round, pos.stage)
+			events = append(events, processor.ContractEvent{
+				EventType: "MissedRound",
+				Timestamp: closedAt,
+				Data: map[string]interface{}{
+					"roundNumber": roundStr,
+					"stageNumber": stageStr,
+					"peerId":      p.PeerID,
+				},
+			})
+		}
+	}
+	return events, statuses
+}
+
+func firstBool(res callResult) (bool, error) {
+	if res.err != nil {
+		return false, res.err
+	}
+	v, ok := res.out[0].(bool)
+	if !ok {
+		return false, fmt.Errorf("unexpected result type %T", res.out[0])
+	}
+	return v, nil
+}
+
+// uintField reads a decimal string produced by normalizeValue.
+func uintField(data map[string]interface{}, key string) (uint64, bool) {
+	s, ok := data[key].(string)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	return v, err == nil
+}
@@ -0,0 +1,109 @@
+package blockchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"gswarm-sidecar/internal/processor"
+)
+
+func newRoundChain(t *testing.T, round, stage int64, submitted bool) (*fakeChain, *contract) {
+	t.Helper()
+	contractABI := loadTestABI(t)
+	chain := &fakeChain{
+		abi: contractABI,
+		results: map[string][]interface{}{
+			"stageCount":                   {big.NewInt(3)},
+			"currentRound":                 {big.NewInt(round)},
+			"currentStage":                 {big.NewInt(stage)},
+			"hasSubmittedRoundStageReward": {submitted},
+		},
+	}
+	return chain, &contract{client: chain, address: testContract, abi: contractABI}
+}
+
+func countEvents(events []processor.ContractEvent, eventType string) int {
+	n := 0
+	for _, ev := range events {
+		if ev.EventType == eventType {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRoundTrackerSeedsWithoutAlerts(t *testing.T) {
+	_, c := newRoundChain(t, 7, 1, false)
+	tracker := &roundTracker{}
+	tracker.setPeers([]peerIdentity{{PeerID: "QmPeer"}})
+
+	// Backfilled events from before the sidecar started.
+	backfill := []processor.ContractEvent{
+		{EventType: "StageAdvanced", Data: map[string]interface{}{"roundNumber": "6", "newStage": "1"}},
+		{EventType: "RoundAdvanced", Data: map[string]interface{}{"newRoundNumber": "7"}},
+		{EventType: "StageAdvanced", Data: map[string]interface{}{"roundNumber": "7", "newStage": "1"}},
+	}
+	var metrics processor.BlockchainMetrics
+	out := tracker.update(context.Background(), c, backfill, &metrics)
+
+	if len(out) != 0 {
+		t.Errorf("first update emitted %d events for stages closed before start", len(out))
+	}
+	if metrics.CurrentRound != 7 || metrics.CurrentStage != 1 || metrics.StageCount != 3 {
+		t.Errorf("round %d stage %d of %d, want round 7 stage 1 of 3", metrics.CurrentRound, metrics.CurrentStage, metrics.StageCount)
+	}
+}
+
+func TestRoundTrackerClosesEveryStagePassed(t *testing.T) {
+	chain, c := newRoundChain(t, 7, 1, false)
+	tracker := &roundTracker{}
+	tracker.setPeers([]peerIdentity{{PeerID: "QmPeer"}})
+	tracker.update(context.Background(), c, nil, &processor.BlockchainMetrics{})
+	tracker.commit()
+
+	// Two stages pass within one poll: 7/1 -> 7/2 -> 8/0.
+	chain.results["currentRound"] = []interface{}{big.NewInt(8)}
+	chain.results["currentStage"] = []interface{}{big.NewInt(0)}
+	var metrics processor.BlockchainMetrics
+	out := tracker.update(context.Background(), c, nil, &metrics)
+
+	if n := countEvents(out, "StageClosed"); n != 2 {
+		t.Errorf("%d StageClosed events, want 2", n)
+	}
+	if n := countEvents(out, "MissedRound"); n != 2 {
+		t.Errorf("%d MissedRound events, want 2", n)
+	}
+	if len(metrics.RoundHistory) != 2 || metrics.RoundHistory[1].RoundNumber != 7 || metrics.RoundHistory[1].StageNumber != 2 {
+		t.Errorf("history = %+v, want round 7 stages 1 and 2", metrics.RoundHistory)
+	}
+	if metrics.CurrentRound != 8 || metrics.CurrentStage != 0 {
+		t.Errorf("now at round %d stage %d, want 8/0", metrics.CurrentRound, metrics.CurrentStage)
+	}
+}
+
+func TestRoundTrackerKeepsStateUntilCommit(t *testing.T) {
+	chain, c := newRoundChain(t, 7, 1, true)
+	tracker := &roundTracker{}
+	tracker.setPeers([]peerIdentity{{PeerID: "QmPeer"}})
+	ctx := context.Background()
+	tracker.update(ctx, c, nil, &processor.BlockchainMetrics{})
+	tracker.commit()
+
+	chain.results["currentStage"] = []interface{}{big.NewInt(2)}
+	// The metrics of the first update are not published, so the next poll
+	// closes stage 1 again.
+	for poll := 0; poll < 2; poll++ {
+		var metrics processor.BlockchainMetrics
+		out := tracker.update(ctx, c, nil, &metrics)
+		if n := countEvents(out, "StageClosed"); n != 1 || len(metrics.RoundHistory) != 1 || metrics.CurrentStage != 2 {
+			t.Fatalf("poll %d: %d StageClosed events, history %+v, stage %d", poll, n, metrics.RoundHistory, metrics.CurrentStage)
+		}
+	}
+
+	tracker.commit()
+	var metrics processor.BlockchainMetrics
+	if out := tracker.update(ctx, c, nil, &metrics); len(out) != 0 || len(metrics.RoundHistory) != 1 {
+		t.Errorf("after commit: events %+v, history %+v", out, metrics.RoundHistory)
+	}
+}
@@ -8,12 +8,113 @@ import (
 )
 
 type TelegramConfig struct {
-	BotToken       string `yaml:"bot_token"`
+	Enabled        *bool  `yaml:"enabled"` // alerting, default true
+	BotToken       string `yaml:"bot_token" secret:"true"`
 	ChatID         string `yaml:"chat_id"`
 	AlertOnDown    bool   `yaml:"alert_on_down"`
 	DownAlertDelay int    `yaml:"down_alert_delay"` // seconds
 }
 
+// SinkConfig configures one destination for processed records.
+type SinkConfig struct {
+	Type          string            `yaml:"type"`                  // gswarm, file, stdout or webhook
+	Include       []string          `yaml:"include"`               // metrics types to deliver, empty means all
+	Exclude       []string          `yaml:"exclude"`               // metrics types to skip
+	BatchSize     int               `yaml:"batch_size"`            // records per write, default 1
+	FlushInterval int               `yaml:"flush_interval"`        // seconds, default 5
+	QueueSize     int               `yaml:"queue_size"`            // records buffered before dropping, default 1000
+	Path          string            `yaml:"path"`                  // file sink
+	URL           string            `yaml:"url"`                   // webhook sink
+	Headers       map[string]string `yaml:"headers" secret:"true"` // webhook sink
+	Timeout       int               `yaml:"timeout"`               // webhook sink, seconds
+}
+
+// MultilineConfig joins physical log lines into one event before parsing.
+// A line matching StartPattern opens an event and following lines matching
+// ContinuationPattern are appended to it. The first other line that does not
+// itself start an event closes it, so the final line of a Python traceback is
+// kept. An empty ContinuationPattern appends every line until the next start.
+type MultilineConfig struct {
+	Disabled            bool   `yaml:"disabled"`
+	StartPattern        string `yaml:"start_pattern"`
+	ContinuationPattern string `yaml:"continuation_pattern"`
+	MaxLines            int    `yaml:"max_lines"` // default 500
+	Timeout             int    `yaml:"timeout"`   // seconds to wait for more lines, default 1
+}
+
+// ThrottleConfig limits the events sent for each log file. Identical
+// messages within DedupWindow are collapsed into one event with a
+// repeat_count, debug events are sampled and the remaining events are rate
+// limited.
+type ThrottleConfig struct {
+	DedupWindow     int     `yaml:"dedup_window"`      // seconds, 0 (default) disables
+	RateLimit       float64 `yaml:"rate_limit"`        // events per second, 0 means unlimited
+	Burst           int     `yaml:"burst"`             // events allowed at once above the rate, default 10 seconds' worth
+	DebugSampleRate float64 `yaml:"debug_sample_rate"` // fraction of debug events sent, default 1
+}
+
+// LogFilterConfig selects log events by level, logger, event type and
+// message. Each list matches if any of its entries does; empty lists are
+// ignored.
+type LogFilterConfig struct {
+	Levels     []string `yaml:"levels"`      // record levels, e.g. debug, info, warning, error
+	Loggers    []string `yaml:"loggers"`     // logger names, also matching their children; globs allowed
+	EventTypes []string `yaml:"event_types"` // event types, e.g. round_event
+	Messages   []string `yaml:"messages"`    // regular expressions matched against the message
+}
+
+// LogFileConfig is one log_files entry. It may be written as a plain path
+// string or as a mapping with per-file settings.
+type LogFileConfig struct {
+	Path      string           `yaml:"path"`   // literal path, glob pattern or directory
+	Parser    string           `yaml:"parser"` // auto (default), swarm, yarn, wandb, json or logfmt
+	Multiline *MultilineConfig `yaml:"multiline"`
+	Throttle  *ThrottleConfig  `yaml:"throttle"`
+	Include   *LogFilterConfig `yaml:"include"` // events must match every list set here
+	Exclude   *LogFilterConfig `yaml:"exclude"` // events matching any list set here are not sent
+}
+
+func (f *LogFileConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		f.Path = value.Value
+		return nil
+	}
+	type plain LogFileConfig
+	return value.Decode((*plain)(f))
+}
+
+// LogRuleConfig maps log messages matching Pattern to events of EventType.
+// Named capture groups in Pattern become event details.
+type LogRuleConfig struct {
+	Name      string            `yaml:"name"`
+	Files     []string          `yaml:"files"`      // glob patterns of the files the rule applies to, empty means all
+	Pattern   string            `yaml:"pattern"`    // regular expression with named capture groups
+	EventType string            `yaml:"event_type"` // required unless action is drop
+	Fields    map[string]string `yaml:"fields"`     // capture group types: string (default), int, float, bool or duration
+	Action    string            `yaml:"action"`     // keep (default) or drop
+}
+
+// ScrubConfig is the PII scrubbing policy applied to log events before they
+// leave the node. The zero value enables the default detectors in redact mode.
+type ScrubConfig struct {
+	Disabled    bool              `yaml:"disabled"`
+	Detectors   []string          `yaml:"detectors"`               // built-in detectors to enable, empty means the defaults
+	Mode        string            `yaml:"mode"`                    // redact (default) or hash
+	Modes       map[string]string `yaml:"modes"`                   // per-detector mode overrides
+	HashSalt    string            `yaml:"hash_salt" secret:"true"` // key for hash mode, default node_id
+	Allowlist   []string          `yaml:"allowlist"`               // regular expressions of values that are never scrubbed
+	AllowFields []string          `yaml:"allow_fields"`            // details keys whose values are never scrubbed
+	Custom      []ScrubRuleConfig `yaml:"custom"`                  // additional named detectors
+}
+
+// ScrubRuleConfig is a user-defined scrubbing detector. When Pattern has a
+// capture group named "value" only that part of a match is replaced.
+type ScrubRuleConfig struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+	Mode    string `yaml:"mode"` // redact or hash, default the policy mode
+}
+
 type Config struct {
 	Logs struct {
 		SwarmLogPath string `yaml:"swarm_log_path"`
@@ -22,68 +123,112 @@ type Config struct {
 	} `yaml:"logs"`
 
 	DHT struct {
-		BootstrapPeers []string `yaml:"bootstrap_peers"`
-		Port           int      `yaml:"port"`
+		Enabled        *bool    `yaml:"enabled"`         // Default true
+		BootstrapPeers []string `yaml:"bootstrap_peers"` // libp2p multiaddrs, e.g. /ip4/1.2.3.4/tcp/38331/p2p/Qm...
+		Port           int      `yaml:"port"`            // Local peer port, probed on 127.0.0.1
+		PollInterval   int      `yaml:"poll_interval"`   // Seconds, default 60
+		DialTimeout    int      `yaml:"dial_timeout"`    // Seconds, default 10
 	} `yaml:"dht"`
 
 	Blockchain struct {
-		ContractAddress   string `yaml:"contract_address"`
-		RPCURL            string `yaml:"rpc_url"`
-		ChainID           int64  `yaml:"chain_id"`
-		ContractABIPath   string `yaml:"contract_abi_path"`
-		PollInterval      int    `yaml:"poll_interval"` // in seconds
-		SendInterval      int    `yaml:"send_interval"` // in seconds, for latest blockchain metrics
-		NodeEOA           string `yaml:"node_eoa"`
-		NodePeerID        string `yaml:"node_peer_id"`
-		ContractABI       string // not mapped to yaml, loaded from file
+		Enabled         *bool    `yaml:"enabled"` // Default true
+		ContractAddress string   `yaml:"contract_address"`
+		RPCURL          string   `yaml:"rpc_url"`
+		ChainID         int64    `yaml:"chain_id"`
+		ContractABIPath string   `yaml:"contract_abi_path"`
+		PollInterval    int      `yaml:"poll_interval"` // in seconds
+		SendInterval    int      `yaml:"send_interval"` // in seconds, for latest blockchain metrics
+		NodeEOA         string   `yaml:"node_eoa"`
+		NodePeerID      string   `yaml:"node_peer_id"`
+		NodeEOAs        []string `yaml:"node_eoas"`       // additional EOAs; all their registered peers are reported
+		NodePeerIDs     []string `yaml:"node_peer_ids"`   // additional peer IDs for operators running several nodes
+		LogBlockRange   int      `yaml:"log_block_range"` // blocks per eth_getLogs request, default 1000
+		StartBlock      uint64   `yaml:"start_block"`     // first block to ingest when no checkpoint exists
+		Confirmations   int      `yaml:"confirmations"`   // blocks behind head before logs are ingested, default 12, -1 for none
+		ContractABI     string   `yaml:"-"`               // loaded from contract_abi_path
 	} `yaml:"blockchain"`
 
 	System struct {
-		MetricsInterval int  `yaml:"metrics_interval"`
-		HealthPort      int  `yaml:"health_port"`
-		PollInterval    int  `yaml:"poll_interval"` // Seconds, default 10
-		EnableGPU       bool `yaml:"enable_gpu"`    // True if NVIDIA GPU present
-		EnableCPU       bool `yaml:"enable_cpu"`    // Default true
-		EnableRAM       bool `yaml:"enable_ram"`    // Default true
-		BatchSize       int  `yaml:"batch_size"`    // Default 10
+		Enabled         *bool `yaml:"enabled"` // Default true
+		MetricsInterval int   `yaml:"metrics_interval"`
+		HealthPort      int   `yaml:"health_port"`
+		PollInterval    int   `yaml:"poll_interval"` // Seconds, default 10
+		EnableGPU       bool  `yaml:"enable_gpu"`    // True if NVIDIA GPU present
+		EnableCPU       *bool `yaml:"enable_cpu"`    // Default true
+		EnableRAM       *bool `yaml:"enable_ram"`    // Default true
+		BatchSize       int   `yaml:"batch_size"`    // Default 10
 	} `yaml:"system"`
 
 	Storage struct {
-		DataPath string `yaml:"data_path"`
+		DataPath       string `yaml:"data_path"`
+		OutboxMaxBytes int64  `yaml:"outbox_max_bytes"` // Default 64 MiB
+		OutboxMaxAge   int    `yaml:"outbox_max_age"`   // Seconds, default 24h
 	} `yaml:"storage"`
 
 	API struct {
 		BaseURL                  string `yaml:"base_url"`
 		MetricsEndpoint          string `yaml:"metrics_endpoint"`
 		HealthEndpoint           string `yaml:"health_endpoint"`
-		AuthToken                string `yaml:"auth_token"`
+		AuthToken                string `yaml:"auth_token" secret:"true"`
 		Timeout                  int    `yaml:"timeout"`
 		RetryCount               int    `yaml:"retry_count"`
 		BlockchainLatestEndpoint string `yaml:"blockchain_latest_endpoint"`
 	} `yaml:"api"`
 
 	LogMonitoring struct {
-		APIEndpoint        string   `yaml:"api_endpoint"`
-		AuthToken          string   `yaml:"auth_token"`
-		BatchSize          int      `yaml:"batch_size"`
-		BatchFlushInterval int      `yaml:"batch_flush_interval"`
-		LogFiles           []string `yaml:"log_files"`
-		InitialTailLines   int      `yaml:"initial_tail_lines"`
+		Enabled            *bool            `yaml:"enabled"` // Default true
+		APIEndpoint        string           `yaml:"api_endpoint"`
+		AuthToken          string           `yaml:"auth_token" secret:"true"`
+		BatchSize          int              `yaml:"batch_size"`
+		BatchFlushInterval int              `yaml:"batch_flush_interval"`
+		LogFiles           []LogFileConfig  `yaml:"log_files"`
+		InitialTailLines   int              `yaml:"initial_tail_lines"`
+		DiscoveryInterval  int              `yaml:"discovery_interval"` // seconds between rescans of log_files patterns
+		Multiline          *MultilineConfig `yaml:"multiline"`          // default for files without their own rule
+		Rules              []LogRuleConfig  `yaml:"rules"`              // checked before the built-in parser
+		Scrubbing          ScrubConfig      `yaml:"scrubbing"`          // PII scrubbing policy
+		Throttle           ThrottleConfig   `yaml:"throttle"`           // default for files without their own settings
+		Include            *LogFilterConfig `yaml:"include"`            // default for files without their own include filter
+		Exclude            *LogFilterConfig `yaml:"exclude"`            // default for files without their own exclude filter
 	} `yaml:"log_monitoring"`
 
 	NodeID   string `yaml:"node_id"`
-	JWTToken string `yaml:"jwt_token"`
+	JWTToken string `yaml:"jwt_token" secret:"true"`
 
 	Telegram TelegramConfig `yaml:"telegram"`
+
+	Sinks []SinkConfig `yaml:"sinks"`
+
+	problems    []Problem // found by Read, reported by Validate
+	secretFiles []string  // read through _FILE environment variables
 }
 
+// Path returns the config file location: CONFIG_PATH, or configs/config.yaml.
+func Path() string {
+	if path := os.Getenv("CONFIG_PATH"); path != "" {
+		return path
+	}
+	return "configs/config.yaml"
+}
+
+// Load reads the config file, applies defaults and validates it. A config
+// with problems is rejected with a *ValidationError listing all of them.
 func Load() (*Config, error) {
-	configPath := "configs/config.yaml"
-	if os.Getenv("CONFIG_PATH") != "" {
-		configPath = os.Getenv("CONFIG_PATH")
+	cfg, err := Read()
+	if err != nil {
+		return nil, err
 	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
 
-	data, err := os.ReadFile(configPath)
+// Read reads the config file, applies GSWARM_* environment overrides and
+// defaults without validating the result. Unknown keys, bad overrides and an
+// unreadable contract ABI are left for Validate to report.
+func Read() (*Config, error) {
+	data, err := os.ReadFile(Path())
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
@@ -92,11 +237,13 @@ func Load() (*Config, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	cfg.problems = unknownFields(data)
+	cfg.applyEnv()
 
 	if cfg.Blockchain.ContractABIPath != "" {
 		abiBytes, err := os.ReadFile(cfg.Blockchain.ContractABIPath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read contract ABI file: %w", err)
+			cfg.problems = append(cfg.problems, Problem{Field: "blockchain.contract_abi_path", Message: fmt.Sprintf("failed to read contract ABI file: %v", err)})
 		}
 		cfg.Blockchain.ContractABI = string(abiBytes)
 	}
@@ -105,15 +252,46 @@ func Load() (*Config, error) {
 	if cfg.System.PollInterval == 0 {
 		cfg.System.PollInterval = 10 // Default 10s
 	}
+	if cfg.System.HealthPort == 0 {
+		cfg.System.HealthPort = 8080 // Default matches docker-compose
+	}
 	if cfg.System.BatchSize == 0 {
 		cfg.System.BatchSize = 10 // Default batch size
 	}
-	if !cfg.System.EnableCPU {
-		cfg.System.EnableCPU = true // Default true
+	defaultBool(&cfg.System.EnableCPU, true)
+	defaultBool(&cfg.System.EnableRAM, true)
+
+	// Every module runs unless explicitly disabled
+	defaultBool(&cfg.LogMonitoring.Enabled, true)
+	defaultBool(&cfg.DHT.Enabled, true)
+	defaultBool(&cfg.Blockchain.Enabled, true)
+	defaultBool(&cfg.System.Enabled, true)
+	defaultBool(&cfg.Telegram.Enabled, true)
+
+	// Set defaults for the persistent outbox
+	if cfg.Storage.DataPath == "" {
+		cfg.Storage.DataPath = "./data"
 	}
-	if !cfg.System.EnableRAM {
-		cfg.System.EnableRAM = true // Default true
+	if cfg.Storage.OutboxMaxBytes == 0 {
+		cfg.Storage.OutboxMaxBytes = 64 * 1024 * 1024 // Default 64 MiB
+	}
+	if cfg.Storage.OutboxMaxAge == 0 {
+		cfg.Storage.OutboxMaxAge = 24 * 60 * 60 // Default 24h
 	}
 
 	return &cfg, nil
 }
+
+// Enabled reports whether a switch is on. Switches are pointers so that an
+// explicit false can be told apart from a missing setting; Read replaces
+// missing ones with their default.
+func Enabled(b *bool) bool {
+	return b != nil && *b
+}
+
+// defaultBool sets a switch that is not set in the config to value.
+func defaultBool(b **bool, value bool) {
+	if *b == nil {
+		*b = &value
+	}
+}
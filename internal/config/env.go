@@ -0,0 +1,220 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix starts the environment variables that override config settings.
+const envPrefix = "GSWARM"
+
+// secretMask replaces secrets in the printed config.
+const secretMask = "********"
+
+var lineNumberPattern = regexp.MustCompile(`^line \d+: `)
+
+// applyEnv overrides settings from the environment. The variable for a
+// setting is its path in the config file in upper case, joined by
+// underscores and prefixed with GSWARM_, e.g. GSWARM_TELEGRAM_BOT_TOKEN for
+// telegram.bot_token. With a _FILE suffix the value is read from that file
+// instead, for secrets mounted by Docker or Kubernetes. Values that cannot be
+// applied are left for Validate to report.
+func (c *Config) applyEnv() {
+	c.applyEnvFields(reflect.ValueOf(c).Elem(), envPrefix)
+}
+
+func (c *Config) applyEnvFields(v reflect.Value, prefix string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := yamlName(f)
+		if name == "" {
+			continue
+		}
+		key := prefix + "_" + strings.ToUpper(name)
+		fv := v.Field(i)
+
+		switch {
+		case f.Type.Kind() == reflect.Struct:
+			c.applyEnvFields(fv, key)
+			continue
+		case f.Type.Kind() == reflect.Pointer && f.Type.Elem().Kind() == reflect.Struct:
+			// Optional sections are only created when a variable sets them.
+			if !envHasPrefix(key + "_") {
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(f.Type.Elem()))
+			}
+			c.applyEnvFields(fv.Elem(), key)
+			continue
+		}
+
+		value, ok := c.envValue(key)
+		if !ok {
+			continue
+		}
+		if err := setFromEnv(fv, value); err != nil {
+			c.problems = append(c.problems, Problem{Field: key, Message: err.Error()})
+		}
+	}
+}
+
+// envValue returns the value of key, or of the file named by key_FILE.
+func (c *Config) envValue(key string) (string, bool) {
+	value, direct := os.LookupEnv(key)
+	path, indirect := os.LookupEnv(key + "_FILE")
+	switch {
+	case direct && indirect:
+		c.problems = append(c.problems, Problem{Field: key, Message: fmt.Sprintf("set either %s or %s_FILE, not both", key, key)})
+		return "", false
+	case indirect:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			c.problems = append(c.problems, Problem{Field: key + "_FILE", Message: fmt.Sprintf("failed to read secret file: %v", err)})
+			return "", false
+		}
+		c.secretFiles = append(c.secretFiles, path)
+		return strings.TrimRight(string(data), "\r\n"), true
+	}
+	return value, direct
+}
+
+// setFromEnv sets a field from an environment value. Strings are used as is,
+// lists may be comma-separated, and everything else is parsed as YAML, e.g.
+// GSWARM_LOG_MONITORING_INCLUDE_LEVELS=info,error or
+// GSWARM_LOG_MONITORING_SCRUBBING_MODES="{ipv4: hash}".
+func setFromEnv(fv reflect.Value, value string) error {
+	if fv.Kind() == reflect.String {
+		fv.SetString(value)
+		return nil
+	}
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+	var node *yaml.Node
+	if fv.Kind() == reflect.Slice && !strings.HasPrefix(trimmed, "[") && !strings.HasPrefix(trimmed, "-") {
+		node = &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: item})
+			}
+		}
+	} else {
+		node = &yaml.Node{}
+		if err := yaml.Unmarshal([]byte(value), node); err != nil {
+			return fmt.Errorf("invalid value %q: %v", value, err)
+		}
+	}
+
+	target := reflect.New(fv.Type())
+	if err := node.Decode(target.Interface()); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			// Line numbers are meaningless for a single variable.
+			for i, msg := range typeErr.Errors {
+				typeErr.Errors[i] = lineNumberPattern.ReplaceAllString(msg, "")
+			}
+			return fmt.Errorf("invalid value %q: %s", value, strings.Join(typeErr.Errors, "; "))
+		}
+		return fmt.Errorf("invalid value %q: %v", value, err)
+	}
+	fv.Set(target.Elem())
+	return nil
+}
+
+func envHasPrefix(prefix string) bool {
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// yamlName returns the key of a field in the config file, or "" for fields
+// that are not read from it.
+func yamlName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// SecretFiles returns the files secrets were read from through _FILE
+// variables, so they can be watched for rotation.
+func (c *Config) SecretFiles() []string {
+	return c.secretFiles
+}
+
+// MaskedYAML returns the config as YAML, as merged from the config file, the
+// environment and defaults, with secrets masked.
+func (c *Config) MaskedYAML() ([]byte, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var masked Config
+	if err := yaml.Unmarshal(data, &masked); err != nil {
+		return nil, err
+	}
+	maskSecrets(reflect.ValueOf(&masked).Elem())
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&masked); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), enc.Close()
+}
+
+// maskSecrets masks the fields tagged secret:"true" in v and everything below
+// it.
+func maskSecrets(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			maskSecrets(v.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			maskSecrets(v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			fv := v.Field(i)
+			if t.Field(i).Tag.Get("secret") != "true" {
+				maskSecrets(fv)
+				continue
+			}
+			switch fv.Kind() {
+			case reflect.String:
+				if fv.String() != "" {
+					fv.SetString(secretMask)
+				}
+			case reflect.Map:
+				for _, k := range fv.MapKeys() {
+					fv.SetMapIndex(k, reflect.ValueOf(secretMask))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is one invalid setting. Field is its path in the config file, e.g.
+// "blockchain.node_eoa" or "log_monitoring.log_files[2].path".
+type Problem struct {
+	Field   string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Field + ": " + p.Message
+}
+
+// ValidationError lists every problem found in a config.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d problem(s) in config:", len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  " + p.String())
+	}
+	return b.String()
+}
+
+// sampleValues are the values of configs/config.yaml and the README that
+// must be replaced before use.
+var sampleValues = map[string]bool{
+	"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...": true,
+	"YOUR_JWT_TOKEN_HERE":                     true,
+	"YOUR_BEARER_TOKEN_HERE":                  true,
+	"0xYourNodeEOA":                           true,
+	"your-unique-peer-id":                     true,
+	"QmSecondPeer...":                         true,
+	"0xSecondNodeEOA...":                      true,
+	"6139877560:AAE...":                       true,
+	"-1001876543210":                          true,
+}
+
+var (
+	// peerIDPattern matches base58btc libp2p peer IDs: Qm... (46 characters),
+	// 12D3KooW... (52) and 16Uiu2HAm... (53).
+	peerIDPattern   = regexp.MustCompile(`^[1-9A-HJ-NP-Za-km-z]{46,60}$`)
+	botTokenPattern = regexp.MustCompile(`^\d+:[A-Za-z0-9_-]{30,}$`)
+	chatIDPattern   = regexp.MustCompile(`^(-?\d+|@[A-Za-z0-9_]{5,})$`)
+	// unknownFieldPattern matches the errors yaml.v3 reports for keys that do
+	// not map onto Config.
+	unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (\S+) not found in type`)
+)
+
+// Validate checks the settings of every module in use and returns a
+// *ValidationError listing all problems, or nil. It expects the defaults
+// applied by Read.
+func (c *Config) Validate() error {
+	v := &validator{problems: append([]Problem(nil), c.problems...)}
+
+	v.required("node_id", c.NodeID)
+	if c.usesGswarmAPI() {
+		v.url("api.base_url", c.API.BaseURL, "http", "https")
+		v.endpoint("api.metrics_endpoint", c.API.MetricsEndpoint)
+		v.endpoint("api.health_endpoint", c.API.HealthEndpoint)
+		v.endpoint("api.blockchain_latest_endpoint", c.API.BlockchainLatestEndpoint)
+		if Enabled(c.LogMonitoring.Enabled) && len(c.LogMonitoring.LogFiles) > 0 {
+			v.endpoint("log_monitoring.api_endpoint", c.LogMonitoring.APIEndpoint)
+		}
+		if c.JWTToken == "" {
+			v.add("jwt_token", "required to send to the gswarm API; copy it from your dashboard at https://gswarm.dev")
+		}
+	}
+	v.secret("jwt_token", c.JWTToken)
+	v.secret("api.auth_token", c.API.AuthToken)
+	v.seconds("api.timeout", c.API.Timeout)
+	v.nonNegative("api.retry_count", c.API.RetryCount)
+
+	// Disabled modules may keep incomplete settings.
+	if Enabled(c.LogMonitoring.Enabled) {
+		c.validateLogMonitoring(v)
+	}
+	if Enabled(c.DHT.Enabled) {
+		c.validateDHT(v)
+	}
+	if Enabled(c.Blockchain.Enabled) {
+		c.validateBlockchain(v)
+	}
+	c.validateSystem(v)
+	if Enabled(c.Telegram.Enabled) {
+		c.validateTelegram(v)
+	}
+	c.validateSinks(v)
+
+	v.nonNegative("storage.outbox_max_bytes", int(c.Storage.OutboxMaxBytes))
+	v.seconds("storage.outbox_max_age", c.Storage.OutboxMaxAge)
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func (c *Config) validateLogMonitoring(v *validator) {
+	lm := c.LogMonitoring
+	v.nonNegative("log_monitoring.batch_size", lm.BatchSize)
+	v.seconds("log_monitoring.batch_flush_interval", lm.BatchFlushInterval)
+	v.nonNegative("log_monitoring.initial_tail_lines", lm.InitialTailLines)
+	v.seconds("log_monitoring.discovery_interval", lm.DiscoveryInterval)
+	for i, f := range lm.LogFiles {
+		v.required(fmt.Sprintf("log_monitoring.log_files[%d].path", i), f.Path)
+	}
+}
+
+func (c *Config) validateDHT(v *validator) {
+	v.seconds("dht.poll_interval", c.DHT.PollInterval)
+	v.seconds("dht.dial_timeout", c.DHT.DialTimeout)
+	if c.DHT.Port < 0 || c.DHT.Port > 65535 {
+		v.add("dht.port", fmt.Sprintf("%d is not a TCP port", c.DHT.Port))
+	}
+	for i, raw := range c.DHT.BootstrapPeers {
+		if !strings.HasPrefix(raw, "/") || !strings.Contains(raw, "/tcp/") {
+			v.add(fmt.Sprintf("dht.bootstrap_peers[%d]", i), fmt.Sprintf("%q is not a multiaddr like /ip4/1.2.3.4/tcp/38331/p2p/Qm...", raw))
+		}
+	}
+}
+
+func (c *Config) validateBlockchain(v *validator) {
+	bc := c.Blockchain
+	// ethclient also accepts IPC socket paths.
+	if !strings.HasPrefix(bc.RPCURL, "/") {
+		v.url("blockchain.rpc_url", bc.RPCURL, "http", "https", "ws", "wss")
+	}
+	if v.required("blockchain.contract_address", bc.ContractAddress) {
+		v.address("blockchain.contract_address", bc.ContractAddress)
+	}
+	if v.required("blockchain.contract_abi_path", bc.ContractABIPath) && bc.ContractABI != "" {
+		if _, err := abi.JSON(strings.NewReader(bc.ContractABI)); err != nil {
+			v.add("blockchain.contract_abi_path", fmt.Sprintf("%s is not a valid contract ABI: %v", bc.ContractABIPath, err))
+		}
+	}
+	v.seconds("blockchain.poll_interval", bc.PollInterval)
+	v.seconds("blockchain.send_interval", bc.SendInterval)
+	v.nonNegative("blockchain.log_block_range", bc.LogBlockRange)
+	if bc.Confirmations < -1 {
+		v.add("blockchain.confirmations", fmt.Sprintf("must be a number of blocks, 0 for the default or -1 for none, got %d", bc.Confirmations))
+	}
+
+	if bc.NodeEOA != "" {
+		v.address("blockchain.node_eoa", bc.NodeEOA)
+	}
+	for i, eoa := range bc.NodeEOAs {
+		v.address(fmt.Sprintf("blockchain.node_eoas[%d]", i), eoa)
+	}
+	if bc.NodePeerID != "" {
+		v.peerID("blockchain.node_peer_id", bc.NodePeerID)
+	}
+	for i, id := range bc.NodePeerIDs {
+		v.peerID(fmt.Sprintf("blockchain.node_peer_ids[%d]", i), id)
+	}
+}
+
+func (c *Config) validateSystem(v *validator) {
+	// The health server runs even with system monitoring disabled.
+	if c.System.HealthPort < 1 || c.System.HealthPort > 65535 {
+		v.add("system.health_port", fmt.Sprintf("%d is not a TCP port", c.System.HealthPort))
+	}
+	if !Enabled(c.System.Enabled) {
+		return
+	}
+	if c.System.PollInterval < 1 {
+		v.add("system.poll_interval", "must be at least 1 second")
+	}
+	if c.System.BatchSize < 1 {
+		v.add("system.batch_size", "must be at least 1")
+	}
+	v.seconds("system.metrics_interval", c.System.MetricsInterval)
+}
+
+func (c *Config) validateTelegram(v *validator) {
+	tg := c.Telegram
+	v.seconds("telegram.down_alert_delay", tg.DownAlertDelay)
+	if !tg.AlertOnDown {
+		return
+	}
+	if v.required("telegram.bot_token", tg.BotToken) && v.secret("telegram.bot_token", tg.BotToken) && !botTokenPattern.MatchString(tg.BotToken) {
+		v.add("telegram.bot_token", "not a bot token like 123456789:AAH...; get one from @BotFather")
+	}
+	if v.required("telegram.chat_id", tg.ChatID) && v.secret("telegram.chat_id", tg.ChatID) && !chatIDPattern.MatchString(tg.ChatID) {
+		v.add("telegram.chat_id", fmt.Sprintf("%q is not a numeric chat ID or @channel name", tg.ChatID))
+	}
+}
+
+func (c *Config) validateSinks(v *validator) {
+	primary := c.primarySink()
+	for i, s := range c.Sinks {
+		field := fmt.Sprintf("sinks[%d]", i)
+		// Log checkpoints advance once the primary sink has taken a record,
+		// so a kind it skips would be acknowledged without being stored.
+		if i == primary && (len(s.Include) > 0 || len(s.Exclude) > 0) {
+			v.add(field, "the primary sink (the first gswarm sink, or else the first sink) must deliver every metrics type; remove its include and exclude")
+		}
+		switch s.Type {
+		case "gswarm", "stdout":
+		case "file":
+			v.required(field+".path", s.Path)
+		case "webhook":
+			v.url(field+".url", s.URL, "http", "https")
+		case "":
+			v.add(field+".type", "required: gswarm, file, stdout or webhook")
+		default:
+			v.add(field+".type", fmt.Sprintf("unknown sink type %q (want gswarm, file, stdout or webhook)", s.Type))
+		}
+		v.nonNegative(field+".batch_size", s.BatchSize)
+		v.seconds(field+".flush_interval", s.FlushInterval)
+		v.nonNegative(field+".queue_size", s.QueueSize)
+		v.seconds(field+".timeout", s.Timeout)
+	}
+}
+
+// primarySink returns the index of the sink whose delivery result is
+// acknowledged: the first gswarm sink, or else the first sink.
+func (c *Config) primarySink() int {
+	for i, s := range c.Sinks {
+		if s.Type == "gswarm" {
+			return i
+		}
+	}
+	return 0
+}
+
+// usesGswarmAPI reports whether records are sent to the gswarm API, which is
+// the case without any sinks configured.
+func (c *Config) usesGswarmAPI() bool {
+	if len(c.Sinks) == 0 {
+		return true
+	}
+	for _, s := range c.Sinks {
+		if s.Type == "gswarm" {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownFields returns a problem for every key in data that does not map
+// onto Config, such as a misspelt setting.
+func unknownFields(data []byte) []Problem {
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	var strict Config
+	var typeErr *yaml.TypeError
+	if err := dec.Decode(&strict); !errors.As(err, &typeErr) {
+		return nil
+	}
+	var problems []Problem
+	for _, msg := range typeErr.Errors {
+		if m := unknownFieldPattern.FindStringSubmatch(msg); m != nil {
+			problems = append(problems, Problem{Field: "line " + m[1], Message: fmt.Sprintf("unknown setting %q", m[2])})
+		}
+	}
+	return problems
+}
+
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) add(field, message string) {
+	v.problems = append(v.problems, Problem{Field: field, Message: message})
+}
+
+// required reports an empty value and returns whether it is set.
+func (v *validator) required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "required")
+		return false
+	}
+	return true
+}
+
+func (v *validator) nonNegative(field string, value int) {
+	if value < 0 {
+		v.add(field, fmt.Sprintf("must not be negative, got %d", value))
+	}
+}
+
+// seconds checks a duration given in seconds, where 0 means the default.
+func (v *validator) seconds(field string, value int) {
+	if value < 0 {
+		v.add(field, fmt.Sprintf("must be a number of seconds, 0 for the default, got %d", value))
+	}
+}
+
+// secret reports a value copied from the sample config and returns whether
+// it is usable.
+func (v *validator) secret(field, value string) bool {
+	if isPlaceholder(value) {
+		v.add(field, fmt.Sprintf("%q is the sample value; replace it with your own", value))
+		return false
+	}
+	return true
+}
+
+// url checks a required absolute URL with one of schemes.
+func (v *validator) url(field, value string, schemes ...string) {
+	if !v.required(field, value) {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		v.add(field, fmt.Sprintf("%q is not a URL: %v", value, err))
+		return
+	}
+	scheme := strings.ToLower(u.Scheme)
+	ok := false
+	for _, s := range schemes {
+		ok = ok || scheme == s
+	}
+	switch {
+	case !ok:
+		prefixes := make([]string, len(schemes))
+		for i, s := range schemes {
+			prefixes[i] = s + "://"
+		}
+		last := len(prefixes) - 1
+		want := prefixes[last]
+		if last > 0 {
+			want = strings.Join(prefixes[:last], ", ") + " or " + want
+		}
+		v.add(field, fmt.Sprintf("%q must start with %s", value, want))
+	case u.Host == "":
+		v.add(field, fmt.Sprintf("%q has no host", value))
+	case isExampleHost(u.Hostname()):
+		v.add(field, fmt.Sprintf("%q is the sample value; replace it with your own", value))
+	}
+}
+
+// endpoint checks an API endpoint, which is either a path appended to
+// api.base_url or an absolute URL.
+func (v *validator) endpoint(field, value string) {
+	switch {
+	case value == "":
+		v.add(field, "required")
+	case strings.HasPrefix(value, "/"):
+	default:
+		v.url(field, value, "http", "https")
+	}
+}
+
+// address checks an Ethereum address. Mixed-case addresses must carry a
+// valid EIP-55 checksum, which catches most typos.
+func (v *validator) address(field, value string) {
+	if !v.secret(field, value) {
+		return
+	}
+	if !common.IsHexAddress(value) {
+		v.add(field, fmt.Sprintf("%q is not an address like 0x followed by 40 hex digits", value))
+		return
+	}
+	digits := strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X")
+	if digits != strings.ToLower(digits) && digits != strings.ToUpper(digits) && common.HexToAddress(value).Hex() != "0x"+digits {
+		v.add(field, fmt.Sprintf("%q has an invalid checksum; check it for typos, or write it in lower case to skip the check", value))
+	}
+}
+
+func (v *validator) peerID(field, value string) {
+	if v.secret(field, value) && !peerIDPattern.MatchString(value) {
+		v.add(field, fmt.Sprintf("%q is not a peer ID like Qm... or 12D3KooW...", value))
+	}
+}
+
+func isPlaceholder(value string) bool {
+	if value == "" {
+		return false
+	}
+	return sampleValues[value]
+}
+
+func isExampleHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range []string{"example.com", "example.org", "example.net"} {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,28 +2,192 @@ package dht
 
 import (
 	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
 
 	"gswarm-sidecar/internal/config"
 	"gswarm-sidecar/internal/processor"
 )
 
+const (
+	defaultPollInterval = 60 * time.Second
+	defaultDialTimeout  = 10 * time.Second
+	localPeerHost       = "127.0.0.1"
+)
+
 type Monitor struct {
 	cfg       *config.Config
 	processor *processor.Processor
+	prober    Prober
+
+	// dialFailures counts failed probes per address since startup.
+	dialFailures map[string]uint64
 }
 
 func New(cfg *config.Config, processor *processor.Processor) *Monitor {
+	dialTimeout := time.Duration(cfg.DHT.DialTimeout) * time.Second
+	if dialTimeout <= 0 {
+		dialTimeout = defaultDialTimeout
+	}
+
 	return &Monitor{
-		cfg:       cfg,
-		processor: processor,
+		cfg:          cfg,
+		processor:    processor,
+		prober:       &MultistreamProber{DialTimeout: dialTimeout},
+		dialFailures: make(map[string]uint64),
 	}
 }
 
+// WithProber replaces the default TCP prober, e.g. with an in-process peer.
+func (m *Monitor) WithProber(prober Prober) *Monitor {
+	m.prober = prober
+	return m
+}
+
 func (m *Monitor) Start(ctx context.Context) {
-	// TODO: Implement DHT monitoring
-	// - Connect to Hivemind DHT
-	// - Monitor peer connections
-	// - Track DHT key patterns
-	// - Send processed data via processor
-	<-ctx.Done()
+	addrs := m.peerAddrs()
+	if len(addrs) == 0 {
+		log.Printf("[dht] No bootstrap peers or local port configured, DHT monitoring idle")
+		<-ctx.Done()
+		return
+	}
+
+	pollInterval := time.Duration(m.cfg.DHT.PollInterval) * time.Second
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	log.Printf("[dht] Monitoring %d peers every %v", len(addrs), pollInterval)
+	m.processor.Status().Register(processor.ComponentDHT, 3*pollInterval)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	m.pollOnce(ctx, addrs)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("[dht] Context done, stopping DHT monitor")
+			return
+		case <-ticker.C:
+			m.pollOnce(ctx, addrs)
+		}
+	}
+}
+
+// peerAddrs returns the configured bootstrap peers plus the local peer
+// listening on DHT.Port, if any.
+func (m *Monitor) peerAddrs() []PeerAddr {
+	addrs := make([]PeerAddr, 0, len(m.cfg.DHT.BootstrapPeers)+1)
+	if m.cfg.DHT.Port > 0 {
+		addrs = append(addrs, PeerAddr{
+			Raw:  fmt.Sprintf("/ip4/%s/tcp/%d", localPeerHost, m.cfg.DHT.Port),
+			Host: localPeerHost,
+			Port: m.cfg.DHT.Port,
+		})
+	}
+	for _, raw := range m.cfg.DHT.BootstrapPeers {
+		addr, err := ParseMultiaddr(raw)
+		if err != nil {
+			log.Printf("[dht] Skipping bootstrap peer: %v", err)
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs
+}
+
+func (m *Monitor) pollOnce(ctx context.Context, addrs []PeerAddr) {
+	results := m.probeAll(ctx, addrs)
+	if ctx.Err() != nil {
+		return
+	}
+
+	metrics := m.buildMetrics(results)
+	log.Printf("[dht] Peer stats: reachable=%d/%d", metrics.ReachablePeers, len(addrs))
+	if err := m.processor.ProcessDHT(ctx, metrics); err != nil {
+		log.Printf("[dht] Failed to process DHT metrics: %v", err)
+	}
+}
+
+// probeAll probes addrs concurrently and returns their results in order.
+func (m *Monitor) probeAll(ctx context.Context, addrs []PeerAddr) []ProbeResult {
+	results := make([]ProbeResult, len(addrs))
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func(i int, addr PeerAddr) {
+			defer wg.Done()
+			// A panicking probe counts as a failed dial of that peer.
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("[dht] Probe of %s panicked: %v\n%s", addr.Raw, r, debug.Stack())
+					results[i] = ProbeResult{Addr: addr, Err: fmt.Errorf("probe panicked: %v", r)}
+				}
+			}()
+			results[i] = m.prober.Probe(ctx, addr)
+		}(i, addr)
+	}
+	wg.Wait()
+	return results
+}
+
+func (m *Monitor) buildMetrics(results []ProbeResult) *processor.DHTMetrics {
+	metrics := &processor.DHTMetrics{
+		ReachablePeerIDs: []string{},
+		NetworkStats:     make(map[string]interface{}),
+	}
+
+	peers := make([]map[string]interface{}, 0, len(results))
+	protocols := make(map[string]int)
+	var totalLatency time.Duration
+	var failures uint64
+
+	for _, r := range results {
+		peer := map[string]interface{}{
+			"addr":      r.Addr.Raw,
+			"peer_id":   r.Addr.PeerID,
+			"reachable": r.Reachable && r.Err == nil,
+		}
+		if r.Reachable {
+			peer["latency_ms"] = float64(r.Latency.Microseconds()) / 1000
+			totalLatency += r.Latency
+		}
+		if r.Multistream != "" {
+			peer["multistream"] = r.Multistream
+			protocols[r.Multistream]++
+		}
+		if len(r.Security) > 0 {
+			peer["security"] = r.Security
+			for _, proto := range r.Security {
+				protocols[proto]++
+			}
+		}
+		if r.Err != nil {
+			m.dialFailures[r.Addr.Raw]++
+			failures++
+			peer["error"] = r.Err.Error()
+		} else {
+			metrics.ReachablePeers++
+			id := r.Addr.PeerID
+			if id == "" {
+				id = r.Addr.Raw
+			}
+			metrics.ReachablePeerIDs = append(metrics.ReachablePeerIDs, id)
+		}
+		peer["dial_failures_total"] = m.dialFailures[r.Addr.Raw]
+		peers = append(peers, peer)
+	}
+
+	metrics.NetworkStats["peers"] = peers
+	metrics.NetworkStats["protocol_versions"] = protocols
+	metrics.NetworkStats["dial_failures"] = failures
+	metrics.NetworkStats["probed_peers"] = len(results)
+	if metrics.ReachablePeers > 0 {
+		avg := totalLatency / time.Duration(metrics.ReachablePeers)
+		metrics.NetworkStats["avg_latency_ms"] = float64(avg.Microseconds()) / 1000
+	}
+	return metrics
 }
@@ -0,0 +1,173 @@
+package dht
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	multistreamProtocol = "/multistream/1.0.0"
+	multistreamNA       = "na"
+	maxMessageLength    = 1024
+)
+
+// securityProtocols are the libp2p security transports RL-Swarm (hivemind's
+// p2pd) negotiates after multistream-select.
+var securityProtocols = []string{"/noise", "/tls/1.0.0"}
+
+// PeerAddr is a parsed libp2p multiaddr of the form
+// /ip4|ip6|dns|dns4|dns6/<host>/tcp/<port>[/p2p/<peer id>].
+type PeerAddr struct {
+	Raw    string
+	Host   string
+	Port   int
+	PeerID string
+}
+
+// HostPort returns the dialable host:port of the address.
+func (a PeerAddr) HostPort() string {
+	return net.JoinHostPort(a.Host, strconv.Itoa(a.Port))
+}
+
+// ParseMultiaddr parses the subset of multiaddrs used for swarm bootstrap peers.
+func ParseMultiaddr(s string) (PeerAddr, error) {
+	addr := PeerAddr{Raw: s}
+	parts := strings.Split(strings.Trim(s, "/"), "/")
+	for i := 0; i+1 < len(parts); i += 2 {
+		value := parts[i+1]
+		switch parts[i] {
+		case "ip4", "ip6", "dns", "dns4", "dns6":
+			addr.Host = value
+		case "tcp":
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return addr, fmt.Errorf("invalid tcp port in %q: %w", s, err)
+			}
+			addr.Port = port
+		case "p2p", "ipfs":
+			addr.PeerID = value
+		default:
+			return addr, fmt.Errorf("unsupported multiaddr protocol %q in %q", parts[i], s)
+		}
+	}
+	if addr.Host == "" || addr.Port == 0 {
+		return addr, fmt.Errorf("multiaddr %q has no host/tcp component", s)
+	}
+	return addr, nil
+}
+
+// ProbeResult describes a single handshake with a peer.
+type ProbeResult struct {
+	Addr        PeerAddr
+	Reachable   bool
+	Latency     time.Duration
+	Multistream string
+	Security    []string
+	Err         error
+}
+
+// Prober checks a single peer. It exists so the monitor can be driven by an
+// in-process peer in place of the live swarm.
+type Prober interface {
+	Probe(ctx context.Context, addr PeerAddr) ProbeResult
+}
+
+// MultistreamProber dials a peer over TCP and runs libp2p multistream-select
+// to learn which multistream and security protocol versions it speaks. It
+// stops before the security handshake, so it tells which configured peers
+// answer, not which peers they are connected to.
+type MultistreamProber struct {
+	DialTimeout time.Duration
+}
+
+func (p *MultistreamProber) Probe(ctx context.Context, addr PeerAddr) ProbeResult {
+	result := ProbeResult{Addr: addr}
+
+	dialer := net.Dialer{Timeout: p.DialTimeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr.HostPort())
+	if err != nil {
+		result.Err = fmt.Errorf("dial failed: %w", err)
+		return result
+	}
+	defer conn.Close()
+	result.Latency = time.Since(start)
+	result.Reachable = true
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(p.DialTimeout))
+	}
+
+	reader := bufio.NewReader(conn)
+	if err := writeMessage(conn, multistreamProtocol); err != nil {
+		result.Err = fmt.Errorf("multistream handshake failed: %w", err)
+		return result
+	}
+	header, err := readMessage(reader)
+	if err != nil {
+		result.Err = fmt.Errorf("multistream handshake failed: %w", err)
+		return result
+	}
+	result.Multistream = header
+
+	// Each proposal is answered with either an echo (accepted) or "na". An
+	// accepted protocol would start its own handshake, so stop at the first one.
+	for _, proto := range securityProtocols {
+		if err := writeMessage(conn, proto); err != nil {
+			result.Err = fmt.Errorf("protocol negotiation failed: %w", err)
+			return result
+		}
+		reply, err := readMessage(reader)
+		if err != nil {
+			result.Err = fmt.Errorf("protocol negotiation failed: %w", err)
+			return result
+		}
+		if reply == proto {
+			result.Security = append(result.Security, proto)
+			break
+		}
+		if reply != multistreamNA {
+			result.Err = fmt.Errorf("unexpected reply %q to %s", reply, proto)
+			return result
+		}
+	}
+	return result
+}
+
+// writeMessage writes a uvarint length-prefixed, newline-terminated message.
+func writeMessage(w io.Writer, msg string) error {
+	buf := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(msg)+1)
+	n := binary.PutUvarint(buf, uint64(len(msg)+1))
+	buf = append(buf[:n], msg...)
+	buf = append(buf, '\n')
+	_, err := w.Write(buf)
+	return err
+}
+
+// readMessage reads one multistream-select message and strips the newline.
+func readMessage(r *bufio.Reader) (string, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if length == 0 || length > maxMessageLength {
+		return "", fmt.Errorf("invalid message length %d", length)
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	if buf[length-1] != '\n' {
+		return "", fmt.Errorf("message not newline terminated")
+	}
+	return string(buf[:length-1]), nil
+}
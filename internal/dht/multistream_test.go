@@ -0,0 +1,136 @@
+package dht
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// servePeer accepts connections on a loopback listener and answers
+// multistream-select like a libp2p host that only supports accepted.
+func servePeer(t *testing.T, accepted string) PeerAddr {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					msg, err := readMessage(reader)
+					if err != nil {
+						return
+					}
+					reply := multistreamNA
+					if msg == multistreamProtocol || msg == accepted {
+						reply = msg
+					}
+					if err := writeMessage(conn, reply); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	addr, err := ParseMultiaddr("/ip4/127.0.0.1/tcp/" + strconv.Itoa(port) + "/p2p/QmTestPeer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+func TestProbeNegotiatesSecurity(t *testing.T) {
+	addr := servePeer(t, "/tls/1.0.0")
+	prober := &MultistreamProber{DialTimeout: time.Second}
+
+	r := prober.Probe(context.Background(), addr)
+	if r.Err != nil {
+		t.Fatalf("probe failed: %v", r.Err)
+	}
+	if !r.Reachable || r.Multistream != multistreamProtocol {
+		t.Errorf("reachable=%v multistream=%q", r.Reachable, r.Multistream)
+	}
+	if len(r.Security) != 1 || r.Security[0] != "/tls/1.0.0" {
+		t.Errorf("security = %v, want [/tls/1.0.0]", r.Security)
+	}
+}
+
+func TestBuildMetricsCountsFailures(t *testing.T) {
+	up := servePeer(t, "/noise")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A port nothing listens on any more.
+	port := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+	down := PeerAddr{Raw: "/ip4/127.0.0.1/tcp/" + strconv.Itoa(port), Host: "127.0.0.1", Port: port}
+
+	m := &Monitor{prober: &MultistreamProber{DialTimeout: time.Second}, dialFailures: make(map[string]uint64)}
+	results := []ProbeResult{
+		m.prober.Probe(context.Background(), up),
+		m.prober.Probe(context.Background(), down),
+	}
+	metrics := m.buildMetrics(results)
+
+	if metrics.ReachablePeers != 1 || len(metrics.ReachablePeerIDs) != 1 || metrics.ReachablePeerIDs[0] != "QmTestPeer" {
+		t.Errorf("reachable_peers=%d ids=%v", metrics.ReachablePeers, metrics.ReachablePeerIDs)
+	}
+	if got := metrics.NetworkStats["dial_failures"]; got != uint64(1) {
+		t.Errorf("dial_failures = %v, want 1", got)
+	}
+	if m.dialFailures[down.Raw] != 1 {
+		t.Errorf("dial failures for %s = %d", down.Raw, m.dialFailures[down.Raw])
+	}
+}
+
+// panickingProber panics on port 1 and reports every other peer reachable.
+type panickingProber struct{}
+
+func (panickingProber) Probe(_ context.Context, addr PeerAddr) ProbeResult {
+	if addr.Port == 1 {
+		panic("boom")
+	}
+	return ProbeResult{Addr: addr, Reachable: true, Latency: time.Millisecond}
+}
+
+func TestPanickingProbeCountsAsFailedDial(t *testing.T) {
+	m := &Monitor{prober: panickingProber{}, dialFailures: make(map[string]uint64)}
+	bad := PeerAddr{Raw: "/ip4/127.0.0.1/tcp/1", Host: "127.0.0.1", Port: 1}
+	good := PeerAddr{Raw: "/ip4/127.0.0.1/tcp/2/p2p/QmGood", Host: "127.0.0.1", Port: 2, PeerID: "QmGood"}
+
+	// The panic fails only its own probe, poll after poll.
+	for poll := uint64(1); poll <= 2; poll++ {
+		results := m.probeAll(context.Background(), []PeerAddr{bad, good})
+		if err := results[0].Err; err == nil || !strings.Contains(err.Error(), "probe panicked: boom") || results[0].Addr != bad {
+			t.Fatalf("poll %d: panicking probe result = %+v", poll, results[0])
+		}
+
+		metrics := m.buildMetrics(results)
+		if metrics.ReachablePeers != 1 || metrics.ReachablePeerIDs[0] != "QmGood" {
+			t.Errorf("poll %d: reachable_peers=%d ids=%v", poll, metrics.ReachablePeers, metrics.ReachablePeerIDs)
+		}
+		if got := metrics.NetworkStats["dial_failures"]; got != uint64(1) {
+			t.Errorf("poll %d: dial_failures = %v, want 1", poll, got)
+		}
+		peer := metrics.NetworkStats["peers"].([]map[string]interface{})[0]
+		if peer["reachable"] != false || peer["dial_failures_total"] != poll {
+			t.Errorf("poll %d: failed peer reported as %v", poll, peer)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package health
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ComponentStatus is the reported state of a single sidecar subsystem.
+type ComponentStatus struct {
+	Ready        bool      `json:"ready"`
+	LastSuccess  time.Time `json:"last_success,omitzero"`
+	LastError    string    `json:"last_error,omitempty"`
+	LastErrorAt  time.Time `json:"last_error_at,omitzero"`
+	SuccessCount uint64    `json:"success_count"`
+	ErrorCount   uint64    `json:"error_count"`
+	StaleAfter   string    `json:"stale_after,omitempty"`
+	Crashes      uint64    `json:"crashes"`
+	LastCrash    string    `json:"last_crash,omitempty"`
+	LastCrashAt  time.Time `json:"last_crash_at,omitzero"`
+}
+
+// FileStatus is the checkpoint of a tailed log file.
+type FileStatus struct {
+	Path      string    `json:"path"`
+	Offset    int64     `json:"offset"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Status is the snapshot served by /status.
+type Status struct {
+	NodeID        string                     `json:"node_id"`
+	StartedAt     time.Time                  `json:"started_at"`
+	UptimeSeconds int64                      `json:"uptime_seconds"`
+	Ready         bool                       `json:"ready"`
+	QueueDepth    int                        `json:"queue_depth"`
+	Components    map[string]ComponentStatus `json:"components"`
+	Files         []FileStatus               `json:"files"`
+}
+
+type component struct {
+	staleAfter   time.Duration
+	lastSuccess  time.Time
+	lastError    string
+	lastErrorAt  time.Time
+	successCount uint64
+	errorCount   uint64
+	crashes      uint64
+	lastCrash    string
+	lastCrashAt  time.Time
+}
+
+// Registry collects success/error reports from the monitors. It is safe for
+// concurrent use.
+type Registry struct {
+	mu         sync.Mutex
+	startedAt  time.Time
+	components map[string]*component
+	files      map[string]FileStatus
+	queueDepth func() int
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		startedAt:  time.Now().UTC(),
+		components: make(map[string]*component),
+		files:      make(map[string]FileStatus),
+	}
+}
+
+// Register declares a component that must report success for the sidecar to
+// be ready. A zero staleAfter only requires a single success; otherwise the
+// last success must be more recent than staleAfter.
+func (r *Registry) Register(name string, staleAfter time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.get(name).staleAfter = staleAfter
+}
+
+// Unregister removes a component that no longer runs, e.g. one disabled on
+// reload, so that it does not hold back readiness.
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.components, name)
+}
+
+// ReportSuccess records a successful cycle of the named component.
+func (r *Registry) ReportSuccess(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.get(name)
+	c.lastSuccess = time.Now().UTC()
+	c.successCount++
+}
+
+// ReportError records a failure of the named component.
+func (r *Registry) ReportError(name string, err error) {
+	if err == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.get(name)
+	c.lastError = err.Error()
+	c.lastErrorAt = time.Now().UTC()
+	c.errorCount++
+}
+
+// ReportCrash records that the named component panicked or stopped on its own
+// and is about to be restarted.
+func (r *Registry) ReportCrash(name string, reason error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.get(name)
+	c.lastCrash = reason.Error()
+	c.lastCrashAt = time.Now().UTC()
+	c.crashes++
+}
+
+// Report records err as a failure, or a success when err is nil.
+func (r *Registry) Report(name string, err error) {
+	if err != nil {
+		r.ReportError(name, err)
+		return
+	}
+	r.ReportSuccess(name)
+}
+
+// SetFileOffset records the committed offset of a tailed file.
+func (r *Registry) SetFileOffset(path string, offset int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files[path] = FileStatus{Path: path, Offset: offset, UpdatedAt: time.Now().UTC()}
+}
+
+// RemoveFile drops a file that is no longer tailed.
+func (r *Registry) RemoveFile(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.files, path)
+}
+
+// SetQueueDepthFunc installs the callback used to report pending deliveries.
+func (r *Registry) SetQueueDepthFunc(fn func() int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queueDepth = fn
+}
+
+// Snapshot returns the current status of every component.
+func (r *Registry) Snapshot() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	status := Status{
+		StartedAt:     r.startedAt,
+		UptimeSeconds: int64(now.Sub(r.startedAt).Seconds()),
+		Ready:         true,
+		Components:    make(map[string]ComponentStatus, len(r.components)),
+		Files:         make([]FileStatus, 0, len(r.files)),
+	}
+
+	for name, c := range r.components {
+		cs := ComponentStatus{
+			Ready:        c.ready(now),
+			LastSuccess:  c.lastSuccess,
+			LastError:    c.lastError,
+			LastErrorAt:  c.lastErrorAt,
+			SuccessCount: c.successCount,
+			ErrorCount:   c.errorCount,
+			Crashes:      c.crashes,
+			LastCrash:    c.lastCrash,
+			LastCrashAt:  c.lastCrashAt,
+		}
+		if c.staleAfter > 0 {
+			cs.StaleAfter = c.staleAfter.String()
+		}
+		if !cs.Ready {
+			status.Ready = false
+		}
+		status.Components[name] = cs
+	}
+
+	for _, f := range r.files {
+		status.Files = append(status.Files, f)
+	}
+	sort.Slice(status.Files, func(i, j int) bool { return status.Files[i].Path < status.Files[j].Path })
+
+	if r.queueDepth != nil {
+		status.QueueDepth = r.queueDepth()
+	}
+	return status
+}
+
+func (r *Registry) get(name string) *component {
+	c, ok := r.components[name]
+	if !ok {
+		c = &component{}
+		r.components[name] = c
+	}
+	return c
+}
+
+func (c *component) ready(now time.Time) bool {
+	if c.lastSuccess.IsZero() {
+		return false
+	}
+	return c.staleAfter == 0 || now.Sub(c.lastSuccess) <= c.staleAfter
+}
@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	readHeaderTimeout = 5 * time.Second
+	shutdownTimeout   = 5 * time.Second
+)
+
+// Server exposes liveness, readiness and status endpoints for the sidecar.
+type Server struct {
+	addr     string
+	nodeID   string
+	registry *Registry
+	mux      *http.ServeMux
+}
+
+func NewServer(port int, nodeID string, registry *Registry) *Server {
+	s := &Server{
+		addr:     fmt.Sprintf(":%d", port),
+		nodeID:   nodeID,
+		registry: registry,
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/healthz", s.handleHealthz)
+	s.mux.HandleFunc("/readyz", s.handleReadyz)
+	s.mux.HandleFunc("/status", s.handleStatus)
+	return s
+}
+
+// Handle registers an additional handler on the server's mux.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start serves until ctx is cancelled.
+func (s *Server) Start(ctx context.Context) {
+	srv := &http.Server{
+		Addr:              s.addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("[health] Failed to shut down health server: %v", err)
+		}
+	}()
+
+	log.Printf("[health] Serving health endpoints on %s", s.addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("[health] Health server stopped: %v", err)
+	}
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+func (s *Server) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	status := s.registry.Snapshot()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if !status.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		for name, c := range status.Components {
+			if !c.Ready {
+				_, _ = fmt.Fprintf(w, "%s: not ready\n", name)
+			}
+		}
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ready\n"))
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	status := s.registry.Snapshot()
+	status.NodeID = s.nodeID
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(status); err != nil {
+		log.Printf("[health] Failed to encode status: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package logs
+
+import (
+	"fmt"
+
+	"gswarm-sidecar/internal/config"
+)
+
+// CheckConfig returns every invalid log monitoring setting in cfg: rules,
+// multi-line rules, throttle and filter settings, parser names and the
+// scrubbing policy. Monitors started with such settings skip or replace them,
+// so a reload uses it to reject a config rather than degrade silently. With
+// log monitoring disabled nothing is checked.
+func CheckConfig(cfg *config.Config) []error {
+	if !config.Enabled(cfg.LogMonitoring.Enabled) {
+		return nil
+	}
+	_, errs := compileRules(cfg.LogMonitoring.Rules)
+	_, scrubErrs := newScrubber(cfg.LogMonitoring.Scrubbing, cfg.NodeID)
+	errs = append(errs, scrubErrs...)
+	if _, err := compileMultiline(cfg.LogMonitoring.Multiline); err != nil {
+		errs = append(errs, fmt.Errorf("multiline: %w", err))
+	}
+	if _, err := newLogThrottle(cfg.LogMonitoring.Throttle, cfg.NodeID); err != nil {
+		errs = append(errs, fmt.Errorf("throttle: %w", err))
+	}
+	if _, err := compileEventFilter(cfg.LogMonitoring.Include, cfg.LogMonitoring.Exclude); err != nil {
+		errs = append(errs, fmt.Errorf("filter: %w", err))
+	}
+
+	for _, fileCfg := range cfg.LogMonitoring.LogFiles {
+		if fileCfg.Path == "" {
+			errs = append(errs, fmt.Errorf("log_files: entry without a path"))
+			continue
+		}
+		if _, err := selectParser(fileCfg.Parser, fileCfg.Path, cfg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", fileCfg.Path, err))
+		}
+		if fileCfg.Multiline != nil {
+			if _, err := compileMultiline(fileCfg.Multiline); err != nil {
+				errs = append(errs, fmt.Errorf("%s: multiline: %w", fileCfg.Path, err))
+			}
+		}
+		if fileCfg.Throttle != nil {
+			if _, err := newLogThrottle(*fileCfg.Throttle, cfg.NodeID); err != nil {
+				errs = append(errs, fmt.Errorf("%s: throttle: %w", fileCfg.Path, err))
+			}
+		}
+		if fileCfg.Include != nil || fileCfg.Exclude != nil {
+			if _, err := compileEventFilter(fileFilters(fileCfg, cfg)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: filter: %w", fileCfg.Path, err))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,258 @@
+package logs
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	checkpointsFile = "log_checkpoints.json"
+	fingerprintSize = 256 // bytes hashed from the start of a file to recognise it
+)
+
+// fileCheckpoint records how far a log file has been delivered.
+type fileCheckpoint struct {
+	Offset          int64  `json:"offset"`
+	Device          uint64 `json:"device"`
+	Inode           uint64 `json:"inode"`
+	Fingerprint     string `json:"fingerprint"`
+	FingerprintSize int64  `json:"fingerprint_size"`
+}
+
+// checkpointStore persists file checkpoints keyed by absolute path.
+type checkpointStore struct {
+	mu          sync.Mutex
+	path        string
+	checkpoints map[string]fileCheckpoint
+}
+
+// loadCheckpointStore reads the checkpoints stored under dataPath. Line-count
+// offsets from the legacy sidecar_offsets.json are converted to byte offsets
+// the first time.
+func loadCheckpointStore(dataPath string) (*checkpointStore, error) {
+	s := &checkpointStore{
+		path:        filepath.Join(dataPath, checkpointsFile),
+		checkpoints: make(map[string]fileCheckpoint),
+	}
+
+	data, err := os.ReadFile(s.path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &s.checkpoints); err != nil {
+			return s, fmt.Errorf("failed to parse log checkpoints: %w", err)
+		}
+	case os.IsNotExist(err):
+		s.migrateLegacyOffsets()
+	default:
+		return s, fmt.Errorf("failed to read log checkpoints: %w", err)
+	}
+	return s, nil
+}
+
+func (s *checkpointStore) get(path string) (fileCheckpoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, ok := s.checkpoints[path]
+	return cp, ok
+}
+
+// set records and persists the checkpoint for path.
+func (s *checkpointStore) set(path string, cp fileCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[path] = cp
+	return s.saveLocked()
+}
+
+// remove forgets the checkpoint for a file that is no longer tailed.
+func (s *checkpointStore) remove(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.checkpoints[path]; !ok {
+		return nil
+	}
+	delete(s.checkpoints, path)
+	return s.saveLocked()
+}
+
+func (s *checkpointStore) saveLocked() error {
+	data, err := json.MarshalIndent(s.checkpoints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal log checkpoints: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create checkpoint dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write log checkpoints: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to persist log checkpoints: %w", err)
+	}
+	return nil
+}
+
+// migrateLegacyOffsets converts line numbers from sidecar_offsets.json in the
+// working directory into byte offsets.
+func (s *checkpointStore) migrateLegacyOffsets() {
+	data, err := os.ReadFile(offsetsFile)
+	if err != nil {
+		return
+	}
+	var legacy map[string]int64
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		log.Printf("[WARN] Ignoring unreadable legacy offsets file %s: %v", offsetsFile, err)
+		return
+	}
+	for path, lines := range legacy {
+		offset, err := byteOffsetOfLine(path, lines)
+		if err != nil {
+			log.Printf("[WARN] Could not migrate legacy offset for %s: %v", path, err)
+			continue
+		}
+		cp, err := checkpointFor(path, offset)
+		if err != nil {
+			log.Printf("[WARN] Could not migrate legacy offset for %s: %v", path, err)
+			continue
+		}
+		s.checkpoints[path] = cp
+		log.Printf("[INFO] Migrated legacy offset for %s: line %d -> byte %d", path, lines, offset)
+	}
+	if len(s.checkpoints) > 0 {
+		if err := s.saveLocked(); err != nil {
+			log.Printf("[WARN] Failed to save migrated checkpoints: %v", err)
+		}
+	}
+}
+
+// checkpointFor builds a checkpoint at offset for the file currently at path.
+func checkpointFor(path string, offset int64) (fileCheckpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return fileCheckpoint{}, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return fileCheckpoint{}, err
+	}
+	fp, fpSize, err := fingerprint(f, fingerprintSize)
+	if err != nil {
+		return fileCheckpoint{}, err
+	}
+	dev, ino := fileIdentity(fi)
+	return fileCheckpoint{Offset: offset, Device: dev, Inode: ino, Fingerprint: fp, FingerprintSize: fpSize}, nil
+}
+
+// fingerprint hashes up to size bytes from the start of f.
+func fingerprint(f io.ReaderAt, size int64) (string, int64, error) {
+	buf := make([]byte, size)
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return "", 0, err
+	}
+	sum := sha256.Sum256(buf[:n])
+	return hex.EncodeToString(sum[:]), int64(n), nil
+}
+
+// byteOffsetOfLine returns the byte offset at which line number lines starts.
+func byteOffsetOfLine(path string, lines int64) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	var offset int64
+	for i := int64(0); i < lines; i++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			break
+		}
+		offset += int64(len(line))
+	}
+	return offset, nil
+}
+
+// tailStartOffset returns the byte offset of the last n lines of path.
+func tailStartOffset(path string, n int) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// Ring buffer of the start offsets of the last n lines.
+	starts := make([]int64, n)
+	count := 0
+	var offset int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			starts[count%n] = offset
+			count++
+			offset += int64(len(line))
+		}
+		if err != nil {
+			break
+		}
+	}
+	if count <= n {
+		return 0, nil
+	}
+	return starts[count%n], nil
+}
+
+// resumeOffset decides where to resume reading path given its checkpoint,
+// detecting rotation, truncation and copy-truncate.
+func resumeOffset(path string, cp fileCheckpoint) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, "", err
+	}
+	dev, ino := fileIdentity(fi)
+	fp, _, err := fingerprint(f, cp.FingerprintSize)
+	if err != nil {
+		return 0, "", err
+	}
+
+	sameContent := fp == cp.Fingerprint
+	sameFile := dev == cp.Device && ino == cp.Inode
+	switch {
+	case fi.Size() < cp.Offset:
+		if sameFile {
+			return 0, "file truncated", nil
+		}
+		return 0, "file rotated", nil
+	case !sameContent:
+		if sameFile {
+			return 0, "file rewritten in place", nil
+		}
+		return 0, "file rotated", nil
+	case ino == cp.Inode:
+		// The same file, possibly on a volume mounted again under another
+		// device.
+		return cp.Offset, "", nil
+	case cp.FingerprintSize >= cp.Offset:
+		// A copy: everything read so far is identical.
+		return cp.Offset, "", nil
+	default:
+		// A new file that starts with the same header, e.g. a banner.
+		return 0, "file rotated", nil
+	}
+}
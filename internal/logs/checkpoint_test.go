@@ -0,0 +1,215 @@
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustCheckpoint(t *testing.T, path string, offset int64) fileCheckpoint {
+	t.Helper()
+	cp, err := checkpointFor(path, offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cp
+}
+
+// A header longer than fingerprintSize, shared by every file of a process.
+var banner = strings.Repeat("=", fingerprintSize) + "\n"
+
+func TestResumeOffset(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		initial    string
+		change     func(t *testing.T, path string)
+		wantOffset bool
+		wantReason string
+	}{
+		{
+			name:       "appended",
+			initial:    banner + "one\n",
+			change:     func(t *testing.T, path string) { appendFile(t, path, "two\n") },
+			wantOffset: true,
+		},
+		{
+			name:       "truncated in place",
+			initial:    banner + "one\n",
+			change:     func(t *testing.T, path string) { _ = os.Truncate(path, 0) },
+			wantReason: "file truncated",
+		},
+		{
+			name:    "copy-truncated and refilled",
+			initial: banner + "one\n",
+			change: func(t *testing.T, path string) {
+				_ = os.Truncate(path, 0)
+				appendFile(t, path, strings.Repeat("x", fingerprintSize)+"\n"+strings.Repeat("y", 100)+"\n")
+			},
+			wantReason: "file rewritten in place",
+		},
+		{
+			name:    "rotated to a new header",
+			initial: "first run\n",
+			change: func(t *testing.T, path string) {
+				_ = os.Rename(path, path+".1")
+				writeFile(t, path, "second run, longer than the first\n")
+			},
+			wantReason: "file rotated",
+		},
+		{
+			name:    "rotated, same header, shorter",
+			initial: banner + "one\ntwo\n",
+			change: func(t *testing.T, path string) {
+				_ = os.Rename(path, path+".1")
+				writeFile(t, path, banner)
+			},
+			wantReason: "file rotated",
+		},
+		{
+			name:    "rotated, same header, longer",
+			initial: banner + "one\n",
+			change: func(t *testing.T, path string) {
+				_ = os.Rename(path, path+".1")
+				writeFile(t, path, banner+"another run\nwith more lines\n")
+			},
+			wantReason: "file rotated",
+		},
+		{
+			name:    "copied whole",
+			initial: "short\n",
+			change: func(t *testing.T, path string) {
+				_ = os.Rename(path, path+".1")
+				writeFile(t, path, "short\nand more\n")
+			},
+			wantOffset: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "swarm.log")
+			writeFile(t, path, tc.initial)
+			cp := mustCheckpoint(t, path, int64(len(tc.initial)))
+			tc.change(t, path)
+
+			offset, reason, err := resumeOffset(path, cp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := int64(0)
+			if tc.wantOffset {
+				want = cp.Offset
+			}
+			if offset != want || reason != tc.wantReason {
+				t.Errorf("resumeOffset = %d, %q; want %d, %q", offset, reason, want, tc.wantReason)
+			}
+		})
+	}
+}
+
+func TestMigrateLegacyOffsets(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	logPath := filepath.Join(dir, "swarm.log")
+	writeFile(t, logPath, "one\ntwo\nthree\n")
+	legacy, _ := json.Marshal(map[string]int64{logPath: 2, filepath.Join(dir, "gone.log"): 5})
+	writeFile(t, offsetsFile, string(legacy))
+
+	store, err := loadCheckpointStore(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, ok := store.get(logPath)
+	if !ok || cp.Offset != int64(len("one\ntwo\n")) {
+		t.Fatalf("migrated checkpoint = %+v, %v; want byte 8", cp, ok)
+	}
+	if _, ok := store.get(filepath.Join(dir, "gone.log")); ok {
+		t.Error("migrated an offset for a missing file")
+	}
+
+	// Migration only happens once; the byte offsets are saved.
+	reloaded, err := loadCheckpointStore(filepath.Join(dir, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reloaded.get(logPath); got != cp {
+		t.Errorf("reloaded %+v, want %+v", got, cp)
+	}
+}
+
+// readLines collects n lines from a running tailer.
+func readLines(t *testing.T, lines <-chan tailLine, n int) []string {
+	t.Helper()
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for len(got) < n {
+		select {
+		case line := <-lines:
+			got = append(got, line.Text)
+		case <-timeout:
+			t.Fatalf("read %q, want %d lines", got, n)
+		}
+	}
+	return got
+}
+
+func TestTailerFollowsRotationAndTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "swarm.log")
+	writeFile(t, path, "old\n")
+	tl, err := openTailer(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines := make(chan tailLine)
+	go tl.Run(ctx, lines)
+
+	if got := readLines(t, lines, 1); got[0] != "old" {
+		t.Fatalf("read %q", got)
+	}
+
+	// Renamed and recreated: the rest of the old file, then the new one.
+	appendFile(t, path, "old tail\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, "new\n")
+	if got := readLines(t, lines, 2); got[0] != "old tail" || got[1] != "new" {
+		t.Fatalf("after rotation read %q", got)
+	}
+
+	// Copy-truncate: the file shrinks in place and is read from the start.
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * tailPollInterval)
+	appendFile(t, path, "fresh\n")
+	got := readLines(t, lines, 1)
+	if got[0] != "fresh" {
+		t.Fatalf("after truncation read %q", got)
+	}
+}
@@ -0,0 +1,147 @@
+package logs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultDirPattern selects the files tailed when a log_files entry names a
+// directory: every *.log file below it, at any depth.
+const defaultDirPattern = "**/*.log"
+
+// hasMeta reports whether path contains glob metacharacters.
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// expandLogPattern resolves one log_files entry to the absolute paths of the
+// regular files it currently matches. An entry is either a literal file path,
+// a directory (watched recursively for *.log files) or a glob pattern in
+// which "**" matches any number of directories.
+func expandLogPattern(pattern string) ([]string, error) {
+	pattern = filepath.Clean(pattern)
+	if !hasMeta(pattern) {
+		fi, err := os.Stat(pattern)
+		switch {
+		case os.IsNotExist(err):
+			return nil, nil
+		case err != nil:
+			return nil, err
+		case fi.IsDir():
+			pattern = filepath.Join(pattern, defaultDirPattern)
+		default:
+			abs, err := filepath.Abs(pattern)
+			if err != nil {
+				return nil, err
+			}
+			return []string{abs}, nil
+		}
+	}
+
+	if !strings.Contains(pattern, "**") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return regularFiles(matches), nil
+	}
+	return globRecursive(pattern)
+}
+
+// globRecursive walks the directory in front of the first wildcard segment and
+// matches every file below it against pattern segment by segment.
+func globRecursive(pattern string) ([]string, error) {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	root := ""
+	i := 0
+	for ; i < len(segments) && !hasMeta(segments[i]); i++ {
+		root = joinSegment(root, segments[i])
+	}
+	if root == "" {
+		root = "."
+	}
+	rest := segments[i:]
+
+	var matches []string
+	err := filepath.WalkDir(filepath.FromSlash(root), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable subdirectories are skipped rather than failing the scan.
+			if d != nil && d.IsDir() && path != filepath.FromSlash(root) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(root), path)
+		if err != nil {
+			return nil
+		}
+		if matchSegments(rest, strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return regularFiles(matches), nil
+}
+
+func joinSegment(root, segment string) string {
+	switch {
+	case root == "" && segment == "":
+		return "/" // leading slash of an absolute pattern
+	case root == "":
+		return segment
+	case strings.HasSuffix(root, "/"):
+		return root + segment
+	default:
+		return root + "/" + segment
+	}
+}
+
+// matchSegments matches path segments against pattern segments, where a "**"
+// segment matches zero or more path segments.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(name); skip++ {
+				if matchSegments(pattern[1:], name[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := filepath.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// regularFiles keeps the regular files among paths, made absolute and sorted.
+func regularFiles(paths []string) []string {
+	files := make([]string, 0, len(paths))
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+		files = append(files, abs)
+	}
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package logs
+
+import "os"
+
+// fileIdentity is unavailable on this platform; rotation is then detected by
+// fingerprint and size alone.
+func fileIdentity(_ os.FileInfo) (dev, ino uint64) {
+	return 0, 0
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package logs
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the device and inode of fi, which stay the same for a
+// file across renames and change when a path is recreated.
+func fileIdentity(fi os.FileInfo) (dev, ino uint64) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+	return uint64(st.Dev), uint64(st.Ino) //nolint:unconvert // Dev is int32 on some platforms
+}
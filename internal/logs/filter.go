@@ -0,0 +1,177 @@
+package logs
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"gswarm-sidecar/internal/config"
+)
+
+// logFilter is a compiled config.LogFilterConfig.
+type logFilter struct {
+	levels     map[string]bool
+	loggers    []string
+	eventTypes map[string]bool
+	messages   []*regexp.Regexp
+}
+
+// eventFilter decides which parsed events of a file are sent. Events that
+// are filtered out are still counted locally. A nil filter sends everything.
+type eventFilter struct {
+	include *logFilter
+	exclude *logFilter
+}
+
+// compileEventFilter compiles the include and exclude filters of a file. It
+// returns nil when neither is set.
+func compileEventFilter(include, exclude *config.LogFilterConfig) (*eventFilter, error) {
+	f := &eventFilter{}
+	var err error
+	if f.include, err = compileLogFilter(include); err != nil {
+		return nil, fmt.Errorf("include: %w", err)
+	}
+	if f.exclude, err = compileLogFilter(exclude); err != nil {
+		return nil, fmt.Errorf("exclude: %w", err)
+	}
+	if f.include == nil && f.exclude == nil {
+		return nil, nil
+	}
+	return f, nil
+}
+
+func compileLogFilter(cfg *config.LogFilterConfig) (*logFilter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	f := &logFilter{
+		levels:     make(map[string]bool, len(cfg.Levels)),
+		eventTypes: make(map[string]bool, len(cfg.EventTypes)),
+	}
+	for _, level := range cfg.Levels {
+		f.levels[normalizeLevel(level)] = true
+	}
+	for _, eventType := range cfg.EventTypes {
+		f.eventTypes[eventType] = true
+	}
+	for _, logger := range cfg.Loggers {
+		if _, err := path.Match(logger, ""); err != nil {
+			return nil, fmt.Errorf("invalid logger pattern %q: %w", logger, err)
+		}
+		f.loggers = append(f.loggers, logger)
+	}
+	for _, expr := range cfg.Messages {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message pattern %q: %w", expr, err)
+		}
+		f.messages = append(f.messages, re)
+	}
+	if len(f.levels) == 0 && len(f.eventTypes) == 0 && len(f.loggers) == 0 && len(f.messages) == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// Allow reports whether event is sent and, if not, why.
+func (f *eventFilter) Allow(event *MetricEvent) (bool, string) {
+	if f == nil {
+		return true, ""
+	}
+	if f.include != nil {
+		if ok, what := f.include.matchesAll(event); !ok {
+			return false, "not included by " + what
+		}
+	}
+	if f.exclude != nil {
+		if ok, what := f.exclude.matchesAny(event); ok {
+			return false, "excluded by " + what
+		}
+	}
+	return true, ""
+}
+
+// matchesAll reports whether event matches every list set, or names the
+// first list it does not match.
+func (f *logFilter) matchesAll(event *MetricEvent) (bool, string) {
+	for _, check := range f.checks() {
+		if check.set && !check.match(event) {
+			return false, check.name
+		}
+	}
+	return true, ""
+}
+
+// matchesAny reports whether event matches any list set, and names it.
+func (f *logFilter) matchesAny(event *MetricEvent) (bool, string) {
+	for _, check := range f.checks() {
+		if check.set && check.match(event) {
+			return true, check.name
+		}
+	}
+	return false, ""
+}
+
+type filterCheck struct {
+	name  string
+	set   bool
+	match func(event *MetricEvent) bool
+}
+
+func (f *logFilter) checks() []filterCheck {
+	return []filterCheck{
+		{"levels", len(f.levels) > 0, f.matchLevel},
+		{"event_types", len(f.eventTypes) > 0, f.matchEventType},
+		{"loggers", len(f.loggers) > 0, f.matchLogger},
+		{"messages", len(f.messages) > 0, f.matchMessage},
+	}
+}
+
+func (f *logFilter) matchLevel(event *MetricEvent) bool {
+	return f.levels[eventLevel(event)]
+}
+
+// eventLevel is the record level of event, or its event type for records
+// without a level.
+func eventLevel(event *MetricEvent) string {
+	if event.Level != "" {
+		return event.Level
+	}
+	return event.EventType
+}
+
+func (f *logFilter) matchEventType(event *MetricEvent) bool {
+	return f.eventTypes[event.EventType]
+}
+
+// matchLogger matches a logger by name, glob or ancestor, so "hivemind"
+// also matches "hivemind.dht.node".
+func (f *logFilter) matchLogger(event *MetricEvent) bool {
+	logger, _ := event.Details["logger"].(string)
+	if logger == "" {
+		return false
+	}
+	for _, pattern := range f.loggers {
+		if logger == pattern || strings.HasPrefix(logger, pattern+".") {
+			return true
+		}
+		if ok, _ := path.Match(pattern, logger); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *logFilter) matchMessage(event *MetricEvent) bool {
+	msg, ok := event.Details["message"].(string)
+	if !ok {
+		msg, _ = event.Details["raw_line"].(string)
+	}
+	for _, re := range f.messages {
+		if re.MatchString(msg) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package logs
+
+import (
+	"testing"
+
+	"gswarm-sidecar/internal/config"
+)
+
+func filterEvent(eventType, level, logger, msg string) *MetricEvent {
+	return &MetricEvent{
+		EventType: eventType,
+		Level:     level,
+		Details:   map[string]interface{}{"logger": logger, "message": msg},
+	}
+}
+
+func TestEventFilter(t *testing.T) {
+	f, err := compileEventFilter(
+		&config.LogFilterConfig{Levels: []string{"WARN", "error"}, Loggers: []string{"hivemind", "genrl.*"}},
+		&config.LogFilterConfig{Messages: []string{`^heartbeat`}, EventTypes: []string{"peer_event"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		event  *MetricEvent
+		allow  bool
+		reason string
+	}{
+		{"included", filterEvent("warning", "warning", "hivemind", "slow"), true, ""},
+		{"child logger", filterEvent("error", "error", "hivemind.dht.node", "failed"), true, ""},
+		{"glob logger", filterEvent("error", "error", "genrl.trainer", "failed"), true, ""},
+		{"logger prefix is not an ancestor", filterEvent("error", "error", "hivemind_exp", "failed"), false, "not included by loggers"},
+		{"level", filterEvent("info", "info", "hivemind", "ok"), false, "not included by levels"},
+		{"level from event type", filterEvent("error", "", "hivemind", "failed"), true, ""},
+		{"no logger", filterEvent("error", "error", "", "failed"), false, "not included by loggers"},
+		// Exclude wins over include.
+		{"excluded message", filterEvent("warning", "warning", "hivemind", "heartbeat missed"), false, "excluded by messages"},
+		{"excluded event type", filterEvent("peer_event", "error", "hivemind", "dropped"), false, "excluded by event_types"},
+	} {
+		allow, reason := f.Allow(tc.event)
+		if allow != tc.allow || reason != tc.reason {
+			t.Errorf("%s: Allow = %v, %q; want %v, %q", tc.name, allow, reason, tc.allow, tc.reason)
+		}
+	}
+}
+
+func TestEventFilterMatchesRawLines(t *testing.T) {
+	f, _ := compileEventFilter(nil, &config.LogFilterConfig{Messages: []string{`DEBUG`}})
+	event := &MetricEvent{EventType: "raw", Details: map[string]interface{}{"raw_line": "[DEBUG] polling"}}
+	if allow, reason := f.Allow(event); allow || reason != "excluded by messages" {
+		t.Errorf("Allow = %v, %q", allow, reason)
+	}
+}
+
+func TestCompileEventFilter(t *testing.T) {
+	f, err := compileEventFilter(nil, &config.LogFilterConfig{})
+	if f != nil || err != nil {
+		t.Errorf("empty filters compiled to %v, %v", f, err)
+	}
+	if allow, _ := f.Allow(filterEvent("debug", "debug", "", "")); !allow {
+		t.Error("nil filter dropped an event")
+	}
+
+	for _, tc := range []struct {
+		include, exclude *config.LogFilterConfig
+	}{
+		{&config.LogFilterConfig{Loggers: []string{"["}}, nil},
+		{nil, &config.LogFilterConfig{Messages: []string{"("}}},
+	} {
+		if _, err := compileEventFilter(tc.include, tc.exclude); err == nil {
+			t.Errorf("%+v / %+v compiled", tc.include, tc.exclude)
+		}
+	}
+}
@@ -6,303 +6,622 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"reflect"
+	"runtime/debug"
 	"strings"
 	"sync"
 	"time"
 
 	"gswarm-sidecar/internal/config"
 	"gswarm-sidecar/internal/processor"
-
-	"bufio"
-
-	"regexp"
-
-	"github.com/hpcloud/tail"
 )
 
 type Monitor struct {
-	cfg       *config.Config
 	processor *processor.Processor
+
+	mu       sync.RWMutex
+	cfg      *config.Config
+	scrubber *scrubber
+	reload   chan struct{} // wakes the file watcher after Reload
 }
 
 // MetricEvent represents a parsed log event/metric
 // (Extend Details as needed for your use case)
-type MetricEvent struct {
-	NodeID    string                 `json:"node_id"`
-	Timestamp time.Time              `json:"timestamp"`
-	EventType string                 `json:"event_type"`
-	Details   map[string]interface{} `json:"details"`
-}
+type MetricEvent = processor.LogEvent
 
 const (
-	splitPartsFull   = 4
-	splitPartsShort  = 2
-	batchPostTimeout = 5 * time.Second
-	statusCodeError  = 300
-	offsetsFile      = "sidecar_offsets.json"
-	maxNilLines      = 10 // Stop tailing after this many consecutive nil lines
+	splitPartsFull  = 4
+	splitPartsShort = 2
+	offsetsFile     = "sidecar_offsets.json" // legacy line-count offsets, migrated on first start
+	// shutdownFlushTimeout bounds sending the last batch of a file on exit.
+	shutdownFlushTimeout = 10 * time.Second
+	// maxUnsentBatches caps the events held per file while sending fails.
+	maxUnsentBatches = 10
 )
 
-type fileOffsets map[string]int64
+func New(cfg *config.Config, processor *processor.Processor) *Monitor {
+	return &Monitor{
+		cfg:       cfg,
+		processor: processor,
+		scrubber:  buildScrubber(cfg),
+		reload:    make(chan struct{}, 1),
+	}
+}
 
-func loadOffsets() (fileOffsets, error) {
-	data, err := ioutil.ReadFile(offsetsFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return make(fileOffsets), nil
-		}
-		return nil, err
+func buildScrubber(cfg *config.Config) *scrubber {
+	scrubber, errs := newScrubber(cfg.LogMonitoring.Scrubbing, cfg.NodeID)
+	for _, err := range errs {
+		log.Printf("[ERROR] %v. Skipping this setting.", err)
 	}
-	var offsets fileOffsets
-	if err := json.Unmarshal(data, &offsets); err != nil {
-		return nil, err
+	if scrubber == nil {
+		log.Printf("[WARN] PII scrubbing is disabled, log events are sent unmodified")
 	}
-	return offsets, nil
+	return scrubber
 }
 
-func saveOffsets(offsets fileOffsets) error {
-	data, err := json.MarshalIndent(offsets, "", "  ")
-	if err != nil {
-		return err
+// Reload applies a new config. The scrubbing policy and Telegram alerting
+// change at once; files are then rescanned, tails started or stopped for
+// added and removed entries, and restarted from their checkpoint when their
+// settings changed. Storage.DataPath is only read at startup.
+func (m *Monitor) Reload(cfg *config.Config) {
+	scrubber := buildScrubber(cfg)
+	m.mu.Lock()
+	m.cfg = cfg
+	m.scrubber = scrubber
+	m.mu.Unlock()
+	select {
+	case m.reload <- struct{}{}:
+	default:
 	}
-	return ioutil.WriteFile(offsetsFile, data, 0644)
 }
 
-func New(cfg *config.Config, processor *processor.Processor) *Monitor {
-	return &Monitor{
-		cfg:       cfg,
-		processor: processor,
-	}
+func (m *Monitor) currentScrubber() *scrubber {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.scrubber
+}
+
+func (m *Monitor) config() *config.Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cfg
 }
 
 func (m *Monitor) Start(ctx context.Context) {
-	offsets, err := loadOffsets()
+	cfg := m.config()
+	store, err := loadCheckpointStore(cfg.Storage.DataPath)
 	if err != nil {
-		log.Printf("[ERROR] Failed to load offsets: %v", err)
-		offsets = make(fileOffsets)
+		log.Printf("[ERROR] Failed to load log checkpoints: %v", err)
+	}
+
+	// Stop the down detector with the monitor, also when it panics and is
+	// restarted.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Channel to receive log activity pings for the down detector
+	activityCh := make(chan struct{}, 1)
+	go func() {
+		// A panicking detector starts over as if activity was just seen.
+		for m.recovered("down detector", func() { m.detectDown(ctx, activityCh) }) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+
+	m.watchLogFiles(ctx, store, activityCh)
+}
+
+// detectDown sends a Telegram alert when no log activity is seen for the
+// configured delay. Settings are read on every check, so a reload can turn
+// alerting on or off and change the chat.
+func (m *Monitor) detectDown(ctx context.Context, activityCh <-chan struct{}) {
+	lastEventTime := time.Now()
+	alertSent := false
+	enabled := false
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-activityCh:
+			lastEventTime = time.Now()
+			if alertSent {
+				log.Printf("[INFO] Node activity resumed, resetting down alert state")
+				alertSent = false
+			}
+		case <-ticker.C:
+			cfg := m.config()
+			tg := cfg.Telegram
+			on := config.Enabled(tg.Enabled) && tg.AlertOnDown && tg.BotToken != "" && tg.ChatID != ""
+			if on != enabled {
+				if on {
+					log.Printf("[INFO] Down detector with Telegram alerting enabled")
+				} else {
+					log.Printf("[INFO] Down detector with Telegram alerting disabled")
+				}
+				enabled = on
+			}
+			delay := time.Duration(tg.DownAlertDelay) * time.Second
+			if delay <= 0 {
+				delay = 300 * time.Second // default 5 min
+			}
+			if enabled && !alertSent && time.Since(lastEventTime) > delay {
+				msg := fmt.Sprintf("[gswarm-sidecar] ALERT: Node '%s' appears DOWN. No log activity for %dm.", cfg.NodeID, int(delay.Minutes()))
+				err := sendTelegramAlert(tg.BotToken, tg.ChatID, msg)
+				if err != nil {
+					log.Printf("[ERROR] Failed to send Telegram alert: %v", err)
+				} else {
+					log.Printf("[INFO] Sent Telegram down alert: %s", msg)
+					alertSent = true
+				}
+			}
+		}
 	}
+}
+
+// recovered calls fn and records a panic in it as a crash of the logs
+// monitor instead of letting it end the process. It reports whether fn
+// panicked.
+func (m *Monitor) recovered(what string, fn func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[ERROR] %s panicked: %v\n%s", what, r, debug.Stack())
+			m.processor.Status().ReportCrash(processor.ComponentLogs, fmt.Errorf("%s panicked: %v", what, r))
+			panicked = true
+		}
+	}()
+	fn()
+	return false
+}
+
+// tailedFile is a file being tailed by watchLogFiles.
+type tailedFile struct {
+	path     string
+	settings any // tailSettings it was started with
+	stop     chan struct{}
+	missing  bool // not matched by the previous scan
+	// Set before stop is closed: restart tails the file again with new
+	// settings, forget drops its checkpoint because the file is gone.
+	restart bool
+	forget  bool
+}
+
+// watchLogFiles tails every file matched by LogMonitoring.LogFiles and rescans
+// the entries periodically, so files that appear while the sidecar runs are
+// picked up and files that disappear are released. After a reload the entries
+// are recompiled and the affected files restarted.
+func (m *Monitor) watchLogFiles(ctx context.Context, store *checkpointStore, activityCh chan<- struct{}) {
+	cfg := m.config()
+	sources := m.logSources(cfg)
 	var wg sync.WaitGroup
+	tailed := make(map[string]*tailedFile)
+	restarting := make(map[string]bool)
+	finished := make(chan *tailedFile)
 
-	// Down detector state
-	if m.cfg.Telegram.AlertOnDown && m.cfg.Telegram.BotToken != "" && m.cfg.Telegram.ChatID != "" {
-		log.Printf("[INFO] Down detector with Telegram alerting enabled")
-		lastEventTime := time.Now()
-		alertSent := false
-		delay := time.Duration(m.cfg.Telegram.DownAlertDelay) * time.Second
-		if delay <= 0 {
-			delay = 300 * time.Second // default 5 min
+	scan := func(initial bool) {
+		if len(sources) > 0 {
+			m.processor.Status().Register(processor.ComponentLogs, 0)
 		}
-		// Channel to receive log activity pings
-		activityCh := make(chan struct{}, 1)
-		// Start down detector goroutine
-		go func() {
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case <-activityCh:
-					lastEventTime = time.Now()
-					if alertSent {
-						log.Printf("[INFO] Node activity resumed, resetting down alert state")
-						alertSent = false
-					}
-				default:
-					time.Sleep(2 * time.Second)
-					if !alertSent && time.Since(lastEventTime) > delay {
-						msg := fmt.Sprintf("[gswarm-sidecar] ALERT: Node '%s' appears DOWN. No log activity for %dm.", m.cfg.NodeID, int(delay.Minutes()))
-						err := sendTelegramAlert(m.cfg.Telegram.BotToken, m.cfg.Telegram.ChatID, msg)
-						if err != nil {
-							log.Printf("[ERROR] Failed to send Telegram alert: %v", err)
-						} else {
-							log.Printf("[INFO] Sent Telegram down alert: %s", msg)
-							alertSent = true
-						}
-					}
+		// A file matched by several entries uses the first one's settings.
+		seen := make(map[string]*logSource)
+		for _, src := range sources {
+			files, err := expandLogPattern(src.cfg.Path)
+			if err != nil {
+				log.Printf("[WARN] Failed to expand log file pattern %s: %v", src.cfg.Path, err)
+				continue
+			}
+			if initial && len(files) == 0 {
+				log.Printf("[WARN] No log files match %s yet. Will keep watching for it.", src.cfg.Path)
+			}
+			for _, path := range files {
+				if seen[path] == nil {
+					seen[path] = src
 				}
 			}
-		}()
-		// Wrap log file tailers to notify activityCh on new events
-		for _, logPath := range m.cfg.LogMonitoring.LogFiles {
-			log.Printf("[INFO] Starting to tail log file: %s", logPath)
-			wg.Add(1)
-			go func(path string) {
-				defer wg.Done()
-				m.tailLogFileWithOffsetAndActivity(ctx, path, offsets, activityCh)
-			}(logPath)
 		}
-	} else {
-		for _, logPath := range m.cfg.LogMonitoring.LogFiles {
-			log.Printf("[INFO] Starting to tail log file: %s", logPath)
+
+		for path, src := range seen {
+			if f, ok := tailed[path]; ok {
+				f.missing = false
+				if !f.restart && !reflect.DeepEqual(f.settings, src.settings) {
+					log.Printf("[INFO] Settings of %s changed, restarting tail", path)
+					f.restart = true
+					close(f.stop)
+				}
+				continue
+			}
+			f := &tailedFile{path: path, settings: src.settings, stop: make(chan struct{})}
+			tailed[path] = f
+			// Files that show up after startup are new, so read them in full.
+			offset := int64(0)
+			if initial || restarting[path] {
+				offset = m.startOffset(path, store)
+			} else if cp, ok := store.get(path); ok {
+				offset = m.resumeFrom(path, cp)
+			}
+			delete(restarting, path)
+			log.Printf("[INFO] Starting to tail log file: %s", path)
 			wg.Add(1)
-			go func(path string) {
+			go func(f *tailedFile, src *logSource, offset int64) {
 				defer wg.Done()
-				m.tailLogFileWithOffset(ctx, path, offsets)
-			}(logPath)
+				// A panic on one file must not take down the others; the
+				// next scan tails the file again from its checkpoint.
+				m.recovered("tail of "+f.path, func() {
+					m.tailLogFile(ctx, src, f, offset, store, activityCh)
+				})
+				select {
+				case finished <- f:
+				case <-ctx.Done():
+				}
+			}(f, src, offset)
 		}
-	}
-	wg.Wait()
-}
 
-// tailLogFile tails a log file and processes new lines in real time
-func (m *Monitor) tailLogFile(ctx context.Context, path string) {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		log.Printf("[WARN] Log file does not exist: %s. Skipping tail for this file.", path)
-		return
-	}
-	// Check if file is empty
-	fi, err := os.Stat(path)
-	if err == nil && fi.Size() == 0 {
-		log.Printf("[WARN] Log file is empty: %s. Skipping tail for this file.", path)
-		return
+		for path, f := range tailed {
+			if seen[path] != nil || f.restart {
+				continue
+			}
+			if _, err := os.Stat(path); err == nil {
+				// Still there, so its entry was removed by a reload. Keep the
+				// checkpoint in case it is added back.
+				log.Printf("[INFO] Log file no longer configured, stopping tail: %s", path)
+				close(f.stop)
+				delete(tailed, path)
+				continue
+			}
+			// Rotation can leave the path briefly missing; only release files
+			// that stay gone for a whole scan interval.
+			if !f.missing {
+				f.missing = true
+				continue
+			}
+			log.Printf("[INFO] Log file disappeared, stopping tail: %s", path)
+			f.forget = true
+			close(f.stop)
+			delete(tailed, path)
+		}
 	}
 
-	t, err := tail.TailFile(path, tail.Config{Follow: true, ReOpen: true, Logger: tail.DiscardingLogger})
-	if err != nil {
-		log.Printf("[ERROR] Failed to tail log file %s: %v\n", path, err)
-		return
-	}
-	log.Printf("[INFO] Successfully tailing log file: %s", path)
-	batch := make([]MetricEvent, 0, m.cfg.LogMonitoring.BatchSize)
-	var nilLineWarned bool
-	nilLineCount := 0
+	scan(true)
+	ticker := time.NewTicker(discoveryInterval(cfg))
+	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf("[INFO] Context done, stopping tail for file: %s", path)
+			wg.Wait()
 			return
-		case line := <-t.Lines:
-			if line == nil {
-				nilLineCount++
-				if !nilLineWarned {
-					log.Printf("[WARN] Received nil line from tail for file: %s (will suppress further warnings)", path)
-					nilLineWarned = true
-				}
-				if nilLineCount >= maxNilLines {
-					log.Printf("[WARN] Too many nil lines from tail for file: %s. Stopping tail for this file.", path)
-					return
-				}
-				continue
+		case f := <-finished:
+			// Let the next scan retry files whose tail ended on its own, and
+			// restart at once those stopped for new settings.
+			if tailed[f.path] == f {
+				delete(tailed, f.path)
 			}
-			nilLineWarned = false // reset if we get a real line
-			nilLineCount = 0
-			log.Printf("[DEBUG] Read new line from %s: %s", path, line.Text)
-			event := parseSwarmLogLine(line.Text, m.cfg)
-			if event != nil {
-				log.Printf("[DEBUG] Created MetricEvent: %+v", *event)
-				batch = append(batch, *event)
-				if len(batch) >= m.cfg.LogMonitoring.BatchSize {
-					log.Printf("[INFO] Batch size reached (%d), sending batch", m.cfg.LogMonitoring.BatchSize)
-					m.postBatch(ctx, batch)
-					batch = batch[:0]
-				}
-			} else {
-				log.Printf("[DEBUG] Skipped line (did not produce MetricEvent): %s", line.Text)
+			if f.restart {
+				restarting[f.path] = true
+				scan(false)
 			}
+		case <-m.reload:
+			cfg = m.config()
+			sources = m.logSources(cfg)
+			ticker.Reset(discoveryInterval(cfg))
+			scan(false)
+		case <-ticker.C:
+			scan(false)
 		}
 	}
 }
 
-// tailLogFileWithOffset tails a log file and processes new lines in real time, with offset tracking
-func (m *Monitor) tailLogFileWithOffset(ctx context.Context, path string, offsets fileOffsets) {
-	absPath, _ := filepath.Abs(path)
-	var seekLine int64 = 0
-	if off, ok := offsets[absPath]; ok {
-		seekLine = off
-		log.Printf("[INFO] Seeking to line %d in %s", seekLine, absPath)
-	} else {
-		// No offset: only ingest last N lines
-		n := m.cfg.LogMonitoring.InitialTailLines
-		if n <= 0 {
-			n = 100 // fallback default
+func discoveryInterval(cfg *config.Config) time.Duration {
+	if cfg.LogMonitoring.DiscoveryInterval > 0 {
+		return time.Duration(cfg.LogMonitoring.DiscoveryInterval) * time.Second
+	}
+	return 10 * time.Second
+}
+
+// logSource is a log_files entry with its settings compiled.
+type logSource struct {
+	cfg       config.LogFileConfig
+	global    *config.Config
+	settings  any // tailSettings, to tell which files a reload affects
+	multiline *multilineRule
+	rules     []*extractionRule     // user-defined rules, selected per file when tailing
+	throttle  config.ThrottleConfig // validated; each file gets its own state
+	filter    *eventFilter
+}
+
+// logSources compiles the per-file settings of every log_files entry. An
+// invalid multiline rule is reported and that entry falls back to single-line
+// events; invalid extraction rules are reported and skipped. Invalid throttle
+// settings are reported and the defaults used instead; an invalid filter is
+// reported and the entry's events are sent unfiltered.
+func (m *Monitor) logSources(cfg *config.Config) []*logSource {
+	rules, errs := compileRules(cfg.LogMonitoring.Rules)
+	for _, err := range errs {
+		log.Printf("[ERROR] %v. Skipping this rule.", err)
+	}
+
+	sources := make([]*logSource, 0, len(cfg.LogMonitoring.LogFiles))
+	for _, fileCfg := range cfg.LogMonitoring.LogFiles {
+		src := &logSource{cfg: fileCfg, global: cfg, settings: tailSettings(fileCfg, cfg), rules: rules}
+		rule, err := compileMultiline(multilineConfig(fileCfg, cfg.LogMonitoring.Multiline))
+		if err != nil {
+			log.Printf("[ERROR] %s: %v. Multi-line assembly disabled for this entry.", fileCfg.Path, err)
 		}
-		// Count total lines in file
-		file, err := os.Open(path)
-		if err == nil {
-			defer file.Close()
-			total := int64(0)
-			scanner := bufio.NewScanner(file)
-			for scanner.Scan() {
-				total++
-			}
-			if err := scanner.Err(); err == nil {
-				seekLine = total - int64(n)
-				if seekLine < 0 {
-					seekLine = 0
-				}
-				log.Printf("[INFO] No offset found, will start ingesting from line %d (last %d lines of %d)", seekLine, n, total)
-			}
+		src.multiline = rule
+
+		src.throttle = cfg.LogMonitoring.Throttle
+		if fileCfg.Throttle != nil {
+			src.throttle = *fileCfg.Throttle
+		}
+		if _, err := newLogThrottle(src.throttle, cfg.NodeID); err != nil {
+			log.Printf("[ERROR] %s: invalid throttle settings: %v. Using the defaults for this entry.", fileCfg.Path, err)
+			src.throttle = config.ThrottleConfig{}
 		}
+
+		filter, err := compileEventFilter(fileFilters(fileCfg, cfg))
+		if err != nil {
+			log.Printf("[ERROR] %s: invalid filter: %v. Sending all events of this entry.", fileCfg.Path, err)
+		}
+		src.filter = filter
+		sources = append(sources, src)
 	}
+	return sources
+}
 
-	t, err := tail.TailFile(path, tail.Config{Follow: true, ReOpen: true, Logger: tail.DiscardingLogger})
+// tailSettings returns everything tailing a file of entry depends on, so
+// files are only restarted by a reload that changes one of them.
+func tailSettings(entry config.LogFileConfig, cfg *config.Config) any {
+	lm := cfg.LogMonitoring
+	// Read per batch or by the watcher rather than by the tail.
+	lm.APIEndpoint, lm.AuthToken = "", ""
+	lm.LogFiles, lm.DiscoveryInterval, lm.InitialTailLines = nil, 0, 0
+	lm.Scrubbing = config.ScrubConfig{}
+	return []any{entry, lm, cfg.Logs, cfg.NodeID}
+}
+
+// fileFilters returns the include and exclude filters of a log_files entry,
+// each falling back to the global one.
+func fileFilters(fileCfg config.LogFileConfig, cfg *config.Config) (include, exclude *config.LogFilterConfig) {
+	include, exclude = fileCfg.Include, fileCfg.Exclude
+	if include == nil {
+		include = cfg.LogMonitoring.Include
+	}
+	if exclude == nil {
+		exclude = cfg.LogMonitoring.Exclude
+	}
+	return include, exclude
+}
+
+// startOffset returns the byte offset to start tailing path from at startup:
+// the stored checkpoint when there is one, otherwise the last
+// InitialTailLines lines.
+func (m *Monitor) startOffset(path string, store *checkpointStore) int64 {
+	if cp, ok := store.get(path); ok {
+		return m.resumeFrom(path, cp)
+	}
+
+	n := m.config().LogMonitoring.InitialTailLines
+	if n <= 0 {
+		n = 100 // fallback default
+	}
+	offset, err := tailStartOffset(path, n)
+	if err != nil {
+		log.Printf("[WARN] Failed to scan %s: %v", path, err)
+		return 0
+	}
+	log.Printf("[INFO] No checkpoint found, will start ingesting %s at byte %d (last %d lines)", path, offset, n)
+	return offset
+}
+
+// resumeFrom returns where to resume path given its checkpoint, falling back
+// to the beginning when the file no longer matches it.
+func (m *Monitor) resumeFrom(path string, cp fileCheckpoint) int64 {
+	offset, reason, err := resumeOffset(path, cp)
+	switch {
+	case err != nil:
+		log.Printf("[WARN] Failed to check checkpoint for %s: %v", path, err)
+		return 0
+	case reason != "":
+		log.Printf("[INFO] Checkpoint for %s no longer matches (%s), starting from the beginning", path, reason)
+	default:
+		log.Printf("[INFO] Resuming %s at byte %d", path, offset)
+	}
+	return offset
+}
+
+// tailLogFile tails a log file from offset and processes new lines in real
+// time until ctx is cancelled or f.stop is closed. Delivered positions are
+// checkpointed by byte offset together with the file's identity, so restarts
+// resume exactly and rotation or truncation is detected.
+func (m *Monitor) tailLogFile(ctx context.Context, src *logSource, f *tailedFile, offset int64, store *checkpointStore, activityCh chan<- struct{}) {
+	path, cfg := f.path, src.global
+	t, err := openTailer(path, offset)
 	if err != nil {
 		log.Printf("[ERROR] Failed to tail log file %s: %v\n", path, err)
 		return
 	}
+	defer t.Close()
 	log.Printf("[INFO] Successfully tailing log file: %s", path)
-	batch := make([]MetricEvent, 0, m.cfg.LogMonitoring.BatchSize)
-	lineNum := int64(0)
-	// Skip lines up to seekLine
-	for lineNum < seekLine {
-		line := <-t.Lines
-		if line == nil {
-			continue
-		}
-		lineNum++
-	}
-
-	// Get batch flush interval from config, default to 10s if not set
+	m.processor.Status().ReportSuccess(processor.ComponentLogs)
+
+	lines := make(chan tailLine)
+	tailCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	runDone := make(chan struct{})
+	go func() {
+		defer close(runDone)
+		m.recovered("reader of "+path, func() { t.Run(tailCtx, lines) })
+	}()
+
+	batch := make([]MetricEvent, 0, cfg.LogMonitoring.BatchSize)
+	var pending fileCheckpoint // checkpoint just past the last line of the last event
 	flushInterval := 10 * time.Second
-	if m.cfg.LogMonitoring.BatchFlushInterval > 0 {
-		flushInterval = time.Duration(m.cfg.LogMonitoring.BatchFlushInterval) * time.Second
+	if cfg.LogMonitoring.BatchFlushInterval > 0 {
+		flushInterval = time.Duration(cfg.LogMonitoring.BatchFlushInterval) * time.Second
 	}
 	flushTimer := time.NewTimer(flushInterval)
 	defer flushTimer.Stop()
 
+	// Lines are joined into multi-line events before parsing; an event still
+	// being assembled is flushed once no more lines arrive for a while.
+	assembler := newMultilineAssembler(src.multiline)
+	parser, err := selectParser(src.cfg.Parser, path, cfg)
+	if err != nil {
+		log.Printf("[ERROR] %s: %v. Using the %s parser.", path, err, parser.Name)
+	}
+	log.Printf("[INFO] Parsing %s with the %s parser", path, parser.Name)
+	rules := rulesForFile(src.rules, path, parser.Rules)
+	var pathFields map[string]interface{}
+	if parser.PathFields != nil {
+		pathFields = parser.PathFields(path)
+	}
+	// Parsed events are sampled, deduplicated and rate limited before batching.
+	throttle, _ := newLogThrottle(src.throttle, cfg.NodeID)
+	var throttleC <-chan time.Time
+	if throttle.Active() {
+		throttleTicker := time.NewTicker(time.Second)
+		defer throttleTicker.Stop()
+		throttleC = throttleTicker.C
+	}
+	if throttle != nil {
+		throttle.onDrop = m.processor.ObserveLogDrop
+	}
+	idleTimer := time.NewTimer(assembler.Timeout())
+	idleTimer.Stop()
+	defer idleTimer.Stop()
+	var idleC <-chan time.Time
+
+	// After a failed send the batch is only retried on the flush timer, and
+	// the oldest events are dropped once it holds maxUnsentBatches batches.
+	retrying := false
+	maxUnsent := max(cfg.LogMonitoring.BatchSize, 1) * maxUnsentBatches
+	enqueue := func(events []MetricEvent) {
+		scrubber := m.currentScrubber()
+		for _, event := range events {
+			// Scrub as events enter the batch, so a batch that is sent again
+			// after a failure is not scrubbed, or counted, twice.
+			for detector, n := range scrubber.Scrub(&event) {
+				m.processor.ObserveScrub(detector, n)
+			}
+			batch = append(batch, event)
+			if over := len(batch) - maxUnsent; over > 0 {
+				log.Printf("[WARN] Dropping %d unsent events for %s", over, path)
+				for i := 0; i < over; i++ {
+					m.processor.ObserveLogDrop(dropBacklog)
+				}
+				batch = append(batch[:0], batch[over:]...)
+			}
+			if !retrying && len(batch) >= cfg.LogMonitoring.BatchSize {
+				log.Printf("[INFO] Batch size reached (%d), sending batch", cfg.LogMonitoring.BatchSize)
+				if m.postBatchWithOffset(ctx, batch, path, pending, store) {
+					batch = batch[:0]
+				} else {
+					retrying = true
+				}
+			}
+		}
+	}
+	handle := func(record tailLine) {
+		pending = record.Checkpoint
+		event, _ := parser.Parse(record.Text, cfg, rules)
+		// Raw lines are not logged: they are only scrubbed once batched.
+		if event == nil {
+			return
+		}
+		addMissing(event.Details, pathFields)
+		m.processor.ObserveLogEvent(event.EventType)
+		if ok, _ := src.filter.Allow(event); !ok {
+			m.processor.ObserveLogDrop(dropFiltered)
+			return
+		}
+		enqueue(throttle.Process(*event, time.Now()))
+		flushTimer.Reset(flushInterval)
+	}
+	// drain hands over everything still held back. Repeats counted in an open
+	// dedup window are not covered by checkpoints, so they are only lost, as
+	// counts, if the sidecar dies without draining.
+	drain := func() {
+		if record, ok := assembler.Flush(); ok {
+			handle(record)
+		}
+		enqueue(throttle.Flush(time.Now()))
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			log.Printf("[INFO] Context done, stopping tail for file: %s", path)
-			// Flush any remaining batch before exit
+			drain()
 			if len(batch) > 0 {
 				log.Printf("[INFO] Flushing remaining batch before exit for file: %s", path)
-				m.postBatchWithOffset(ctx, batch, absPath, lineNum, offsets)
+				// ctx is already cancelled and would fail the send at once.
+				flushCtx, cancel := context.WithTimeout(context.Background(), shutdownFlushTimeout)
+				m.postBatchWithOffset(flushCtx, batch, path, pending, store)
+				cancel()
 			}
 			return
-		case line := <-t.Lines:
-			if line == nil {
-				log.Printf("[WARN] Received nil line from tail for file: %s", path)
+		case <-f.stop:
+			drain()
+			if len(batch) > 0 {
+				m.postBatchWithOffset(ctx, batch, path, pending, store)
+			}
+			if !f.restart {
+				m.processor.Status().RemoveFile(path)
+			}
+			if f.forget {
+				if err := store.remove(path); err != nil {
+					log.Printf("[ERROR] Failed to remove log checkpoint: %v", err)
+				}
+			}
+			return
+		case <-runDone:
+			// Run only returns early when it panicked. Send what was read;
+			// the next scan tails the file again from its checkpoint.
+			runDone = nil
+			if ctx.Err() != nil {
 				continue
 			}
-			log.Printf("[DEBUG] Read new line from %s: %s", path, line.Text)
-			lineNum++
-			event := parseSwarmLogLine(line.Text, m.cfg)
-			if event != nil {
-				log.Printf("[DEBUG] Created MetricEvent: %+v", *event)
-				batch = append(batch, *event)
-				if len(batch) >= m.cfg.LogMonitoring.BatchSize {
-					log.Printf("[INFO] Batch size reached (%d), sending batch", m.cfg.LogMonitoring.BatchSize)
-					if m.postBatchWithOffset(ctx, batch, absPath, lineNum, offsets) {
-						batch = batch[:0]
-					}
-					flushTimer.Reset(flushInterval)
-				} else {
-					// Reset timer on new line if batch not full
-					flushTimer.Reset(flushInterval)
+			drain()
+			if len(batch) > 0 {
+				m.postBatchWithOffset(ctx, batch, path, pending, store)
+			}
+			return
+		case line := <-lines:
+			// Notify activity
+			if activityCh != nil {
+				select {
+				case activityCh <- struct{}{}:
+				default:
 				}
-			} else {
-				log.Printf("[DEBUG] Skipped line (did not produce MetricEvent): %s", line.Text)
 			}
+			for _, record := range assembler.Add(line) {
+				handle(record)
+			}
+			idleC = nil
+			if assembler.Pending() {
+				idleTimer.Reset(assembler.Timeout())
+				idleC = idleTimer.C
+			}
+		case <-idleC:
+			idleC = nil
+			if record, ok := assembler.Flush(); ok {
+				handle(record)
+			}
+		case now := <-throttleC:
+			enqueue(throttle.Tick(now))
 		case <-flushTimer.C:
 			if len(batch) > 0 {
 				log.Printf("[INFO] Batch flush interval reached, sending batch of %d for file: %s", len(batch), path)
-				if m.postBatchWithOffset(ctx, batch, absPath, lineNum, offsets) {
+				if m.postBatchWithOffset(ctx, batch, path, pending, store) {
 					batch = batch[:0]
+					retrying = false
 				}
 			}
 			flushTimer.Reset(flushInterval)
@@ -310,29 +629,48 @@ func (m *Monitor) tailLogFileWithOffset(ctx context.Context, path string, offset
 	}
 }
 
-// parseSwarmLogLine parses a line from swarm.log and returns a MetricEvent if relevant
-func parseSwarmLogLine(line string, cfg *config.Config) *MetricEvent {
-	parts := strings.SplitN(line, " - ", splitPartsFull)
+// parseSwarmLogLine parses a line in the Python "asctime - level - name - msg"
+// format of the swarm launcher log and returns a MetricEvent if relevant, together with the extraction rule that matched it (nil for
+// level-based and raw events). A rule with a nil event means it was dropped.
+func parseSwarmLogLine(line string, cfg *config.Config, rules []*extractionRule) (*MetricEvent, *extractionRule) {
+	// Multi-line events carry the log record header on their first line.
+	first, rest, _ := strings.Cut(line, "\n")
+	parts := strings.SplitN(first, " - ", splitPartsFull)
 	if len(parts) < splitPartsFull {
-		log.Printf("[DEBUG] Line does not match expected format, sending as raw: %s", line)
-		return &MetricEvent{
-			NodeID:    cfg.NodeID,
-			Timestamp: time.Now(),
-			EventType: "raw",
-			Details: map[string]interface{}{
-				"raw_line": line,
-			},
+		// Wrappers around RL-Swarm may write JSON lines or logfmt instead.
+		if fields, ok := detectStructured(line); ok {
+			return structuredEvent(fields, cfg, rules)
+		}
+		if event, rule, ok := tracebackEvent(line, time.Now(), "", "", cfg, rules); ok {
+			return event, rule
 		}
+		return rawEvent(line, cfg, rules)
 	}
 	ts, err := time.Parse("2006-01-02 15:04:05,000", parts[0])
 	if err != nil {
-		log.Printf("[WARN] Failed to parse timestamp, using current time. Line: %s, Error: %v", line, err)
+		log.Printf("[WARN] Failed to parse timestamp, using current time: %v", err)
 		ts = time.Now()
 	}
-	level := strings.TrimSpace(parts[1])
+	level := normalizeLevel(parts[1])
 	logger := strings.TrimSpace(parts[2])
 	msg := strings.TrimSpace(parts[3])
 
+	if event, rule, ok := tracebackEvent(rest, ts, logger, msg, cfg, rules); ok {
+		return withLevel(event, level), rule
+	}
+	if rest != "" {
+		msg += "\n" + rest
+	}
+
+	// Configured rules, then known swarm messages, become typed events
+	details := map[string]interface{}{
+		"logger":  logger,
+		"message": msg,
+	}
+	if event, rule := ruleEvent(rules, cfg, ts, details, msg); rule != nil {
+		return withLevel(event, level), rule
+	}
+
 	// Special case: peer join event
 	if strings.Contains(msg, "Joining swarm with initial_peers") {
 		peers := extractPeersFromLine(msg)
@@ -347,30 +685,69 @@ func parseSwarmLogLine(line string, cfg *config.Config) *MetricEvent {
 				"logger": logger,
 				"raw":    msg,
 			},
-		}
+			Level: level,
+		}, nil
 	}
 
-	// General case: emit an event for every log line
-	eventType := strings.ToLower(level)
-	switch eventType {
-	case "error":
-		eventType = "error"
-	case "info":
-		eventType = "info"
-	case "debug":
-		eventType = "debug"
-		// extend as needed
+	// General case: an event of the record's level; include/exclude filters
+	// decide which are sent
+	return &MetricEvent{
+		NodeID:    cfg.NodeID,
+		Timestamp: ts,
+		EventType: level,
+		Details:   details,
+		Level:     level,
+	}, nil
+}
+
+// withLevel sets the record level of event, if any.
+func withLevel(event *MetricEvent, level string) *MetricEvent {
+	if event != nil {
+		event.Level = level
+	}
+	return event
+}
+
+// addMissing copies fields into details without overwriting existing keys.
+func addMissing(details, fields map[string]interface{}) {
+	for k, v := range fields {
+		if _, ok := details[k]; !ok {
+			details[k] = v
+		}
 	}
+}
 
+// tracebackEvent returns an error event when text contains a Python
+// traceback, with the exception type, message and stack split out. ok is
+// false when there is no traceback.
+func tracebackEvent(text string, ts time.Time, logger, message string, cfg *config.Config, rules []*extractionRule) (*MetricEvent, *extractionRule, bool) {
+	excType, excMsg, stack, ok := parseTraceback(text)
+	if !ok {
+		return nil, nil, false
+	}
+	details := map[string]interface{}{
+		"exception_type":    excType,
+		"exception_message": excMsg,
+		"stack_trace":       stack,
+	}
+	if logger != "" {
+		details["logger"] = logger
+	}
+	if message != "" {
+		details["message"] = message
+	}
+	// Tag known failures such as OOM without changing the event type.
+	rule := applyRules(rules, details, excType+": "+excMsg)
+	if rule != nil && rule.Drop {
+		return nil, rule, true
+	}
 	return &MetricEvent{
 		NodeID:    cfg.NodeID,
 		Timestamp: ts,
-		EventType: eventType,
-		Details: map[string]interface{}{
-			"logger":  logger,
-			"message": msg,
-		},
-	}
+		EventType: "error",
+		Details:   details,
+		Level:     "error",
+	}, rule, true
 }
 
 // extractPeersFromLine extracts peer addresses from a log line
@@ -378,7 +755,6 @@ func extractPeersFromLine(line string) []string {
 	start := strings.Index(line, "[")
 	end := strings.Index(line, "]")
 	if start == -1 || end == -1 || end <= start {
-		log.Printf("[DEBUG] Could not extract peers from line: %s", line)
 		return nil
 	}
 	peersStr := line[start+1 : end]
@@ -389,99 +765,28 @@ func extractPeersFromLine(line string) []string {
 	return peers
 }
 
-// postBatch posts a batch of MetricEvents to the API
-func (m *Monitor) postBatch(ctx context.Context, batch []MetricEvent) {
-	// Scrub PII from all events before sending
-	for i := range batch {
-		scrubPII(&batch[i])
-	}
-	data, err := json.MarshalIndent(batch, "", "  ")
-	if err != nil {
-		log.Printf("[ERROR] Failed to marshal batch: %v\n", err)
-		return
-	}
-
-	// Debug: print the batch payload being sent
-	log.Printf("[DEBUG] Sending batch payload: %s\n", string(data))
-
-	apiURL := m.cfg.LogMonitoring.APIEndpoint
-	authToken := m.cfg.JWTToken
-	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(data))
-	if err != nil {
-		log.Printf("[ERROR] Failed to create request: %v\n", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	if authToken != "" {
-		req.Header.Set("Authorization", "Bearer "+authToken)
-	}
-	client := &http.Client{Timeout: batchPostTimeout}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("[ERROR] Failed to POST batch: %v\n", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Debug: print the response status and body
-	respBody, _ := io.ReadAll(resp.Body)
-	log.Printf("[DEBUG] API response status: %d, body: %s\n", resp.StatusCode, string(respBody))
-
-	if resp.StatusCode >= statusCodeError {
-		log.Printf("[ERROR] API returned status %d\n", resp.StatusCode)
-	} else {
-		log.Printf("[INFO] Successfully posted batch of %d events", len(batch))
-	}
-}
-
-// postBatchWithOffset posts a batch of MetricEvents to the API, with offset tracking
-func (m *Monitor) postBatchWithOffset(ctx context.Context, batch []MetricEvent, absPath string, lineNum int64, offsets fileOffsets) bool {
-	// Scrub PII from all events before sending
-	for i := range batch {
-		scrubPII(&batch[i])
-	}
-	data, err := json.MarshalIndent(batch, "", "  ")
-	if err != nil {
-		log.Printf("[ERROR] Failed to marshal batch: %v\n", err)
+// postBatch sends a batch of scrubbed MetricEvents through the processor
+// pipeline
+func (m *Monitor) postBatch(ctx context.Context, batch []MetricEvent) bool {
+	if err := m.processor.ProcessLogBatch(ctx, batch); err != nil {
+		log.Printf("[ERROR] Failed to send batch of %d events: %v", len(batch), err)
 		return false
 	}
+	log.Printf("[INFO] Successfully sent batch of %d events", len(batch))
+	return true
+}
 
-	log.Printf("[DEBUG] Sending batch payload: %s\n", string(data))
-
-	apiURL := m.cfg.LogMonitoring.APIEndpoint
-	authToken := m.cfg.JWTToken
-	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(data))
-	if err != nil {
-		log.Printf("[ERROR] Failed to create request: %v\n", err)
-		return false
-	}
-	req.Header.Set("Content-Type", "application/json")
-	if authToken != "" {
-		req.Header.Set("Authorization", "Bearer "+authToken)
-	}
-	client := &http.Client{Timeout: batchPostTimeout}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("[ERROR] Failed to POST batch: %v\n", err)
+// postBatchWithOffset sends a batch of MetricEvents and commits the file
+// checkpoint once the pipeline has confirmed delivery (or durable queueing)
+func (m *Monitor) postBatchWithOffset(ctx context.Context, batch []MetricEvent, absPath string, cp fileCheckpoint, store *checkpointStore) bool {
+	if !m.postBatch(ctx, batch) {
 		return false
 	}
-	defer resp.Body.Close()
-
-	respBody, _ := io.ReadAll(resp.Body)
-	log.Printf("[DEBUG] API response status: %d, body: %s\n", resp.StatusCode, string(respBody))
-
-	if resp.StatusCode >= statusCodeError {
-		log.Printf("[ERROR] API returned status %d\n", resp.StatusCode)
-		return false
-	} else {
-		log.Printf("[INFO] Successfully posted batch of %d events", len(batch))
-		offsets[absPath] = lineNum
-		err := saveOffsets(offsets)
-		if err != nil {
-			log.Printf("[ERROR] Failed to save offsets: %v", err)
-		}
-		return true
+	m.processor.Status().SetFileOffset(absPath, cp.Offset)
+	if err := store.set(absPath, cp); err != nil {
+		log.Printf("[ERROR] Failed to save log checkpoint: %v", err)
 	}
+	return true
 }
 
 // sendTelegramAlert sends a message to the configured Telegram chat using the bot token and chat ID.
@@ -506,179 +811,3 @@ func sendTelegramAlert(botToken, chatID, message string) error {
 	}
 	return nil
 }
-
-// Add a new tailLogFileWithOffsetAndActivity method
-func (m *Monitor) tailLogFileWithOffsetAndActivity(ctx context.Context, path string, offsets fileOffsets, activityCh chan<- struct{}) {
-	// Check if file exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		log.Printf("[WARN] Log file does not exist: %s. Skipping tail for this file.", path)
-		return
-	}
-	// Check if file is empty
-	fi, err := os.Stat(path)
-	if err == nil && fi.Size() == 0 {
-		log.Printf("[WARN] Log file is empty: %s. Skipping tail for this file.", path)
-		return
-	}
-
-	absPath, _ := filepath.Abs(path)
-	var seekLine int64 = 0
-	if off, ok := offsets[absPath]; ok {
-		seekLine = off
-		log.Printf("[INFO] Seeking to line %d in %s", seekLine, absPath)
-	} else {
-		n := m.cfg.LogMonitoring.InitialTailLines
-		if n <= 0 {
-			n = 100 // fallback default
-		}
-		file, err := os.Open(path)
-		if err == nil {
-			defer file.Close()
-			total := int64(0)
-			scanner := bufio.NewScanner(file)
-			for scanner.Scan() {
-				total++
-			}
-			if err := scanner.Err(); err == nil {
-				seekLine = total - int64(n)
-				if seekLine < 0 {
-					seekLine = 0
-				}
-				log.Printf("[INFO] No offset found, will start ingesting from line %d (last %d lines of %d)", seekLine, n, total)
-			}
-		}
-	}
-	t, err := tail.TailFile(path, tail.Config{Follow: true, ReOpen: true, Logger: tail.DiscardingLogger})
-	if err != nil {
-		log.Printf("[ERROR] Failed to tail log file %s: %v\n", path, err)
-		return
-	}
-	log.Printf("[INFO] Successfully tailing log file: %s", path)
-	batch := make([]MetricEvent, 0, m.cfg.LogMonitoring.BatchSize)
-	lineNum := int64(0)
-	flushInterval := 10 * time.Second
-	if m.cfg.LogMonitoring.BatchFlushInterval > 0 {
-		flushInterval = time.Duration(m.cfg.LogMonitoring.BatchFlushInterval) * time.Second
-	}
-	flushTimer := time.NewTimer(flushInterval)
-	defer flushTimer.Stop()
-	// Skip lines up to seekLine
-	for lineNum < seekLine {
-		line := <-t.Lines
-		if line == nil {
-			continue
-		}
-		lineNum++
-	}
-	var nilLineWarned bool
-	nilLineCount := 0
-	for {
-		select {
-		case <-ctx.Done():
-			log.Printf("[INFO] Context done, stopping tail for file: %s", path)
-			if len(batch) > 0 {
-				log.Printf("[INFO] Flushing remaining batch before exit for file: %s", path)
-				m.postBatchWithOffset(ctx, batch, absPath, lineNum, offsets)
-			}
-			return
-		case line := <-t.Lines:
-			if line == nil {
-				nilLineCount++
-				if !nilLineWarned {
-					log.Printf("[WARN] Received nil line from tail for file: %s (will suppress further warnings)", path)
-					nilLineWarned = true
-				}
-				if nilLineCount >= maxNilLines {
-					log.Printf("[WARN] Too many nil lines from tail for file: %s. Stopping tail for this file.", path)
-					return
-				}
-				continue
-			}
-			nilLineWarned = false // reset if we get a real line
-			nilLineCount = 0
-			// Notify activity
-			select { case activityCh <- struct{}{}: default: }
-			log.Printf("[DEBUG] Read new line from %s: %s", path, line.Text)
-			lineNum++
-			event := parseSwarmLogLine(line.Text, m.cfg)
-			if event != nil {
-				log.Printf("[DEBUG] Created MetricEvent: %+v", *event)
-				batch = append(batch, *event)
-				if len(batch) >= m.cfg.LogMonitoring.BatchSize {
-					log.Printf("[INFO] Batch size reached (%d), sending batch", m.cfg.LogMonitoring.BatchSize)
-					if m.postBatchWithOffset(ctx, batch, absPath, lineNum, offsets) {
-						batch = batch[:0]
-					}
-					flushTimer.Reset(flushInterval)
-				} else {
-					flushTimer.Reset(flushInterval)
-				}
-			} else {
-				log.Printf("[DEBUG] Skipped line (did not produce MetricEvent): %s", line.Text)
-			}
-		case <-flushTimer.C:
-			if len(batch) > 0 {
-				log.Printf("[INFO] Batch flush interval reached, sending batch of %d for file: %s", len(batch), path)
-				if m.postBatchWithOffset(ctx, batch, absPath, lineNum, offsets) {
-					batch = batch[:0]
-				}
-			}
-			flushTimer.Reset(flushInterval)
-		}
-	}
-}
-
-// --- PII Scrubber ---
-// scrubPII redacts emails, IP addresses, environment settings, and wallet addresses from a MetricEvent (recursively)
-func scrubPII(event *MetricEvent) {
-	// Regex patterns
-	emailRegex := regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
-	ipRegex := regexp.MustCompile(`\b(?:[0-9]{1,3}\.){3}[0-9]{1,3}\b`)
-	walletRegex := regexp.MustCompile(`0x[a-fA-F0-9]{40}`)
-	envVarRegex := regexp.MustCompile(`(?i)(API_KEY|SECRET|PASSWORD|TOKEN|JWT|PRIVATE_KEY|ENV|CONFIG|DATABASE_URL|DB_PASS|ACCESS_KEY|SECRET_KEY)=[^\s]+`)
-	// Serial numbers and device IDs (UUID, GUID, HWID, etc.)
-	serialRegex := regexp.MustCompile(`(?i)(serial|device[_-]?id|uuid|guid|hwid|cpuid|gpuid)[\s:=]+[a-zA-Z0-9\-]{6,}`)
-	// Generic long hex strings (potential device IDs)
-	longHexRegex := regexp.MustCompile(`\b[a-fA-F0-9]{16,}\b`)
-
-	event.Details = scrubMap(event.Details, emailRegex, ipRegex, walletRegex, envVarRegex, serialRegex, longHexRegex)
-}
-
-func scrubMap(m map[string]interface{}, regexes ...*regexp.Regexp) map[string]interface{} {
-	for k, v := range m {
-		switch val := v.(type) {
-		case string:
-			for _, re := range regexes {
-				if re.MatchString(val) {
-					val = re.ReplaceAllString(val, "[REDACTED]")
-				}
-			}
-			m[k] = val
-		case map[string]interface{}:
-			m[k] = scrubMap(val, regexes...)
-		case []interface{}:
-			m[k] = scrubSlice(val, regexes...)
-		}
-	}
-	return m
-}
-
-func scrubSlice(arr []interface{}, regexes ...*regexp.Regexp) []interface{} {
-	for i, v := range arr {
-		switch val := v.(type) {
-		case string:
-			for _, re := range regexes {
-				if re.MatchString(val) {
-					val = re.ReplaceAllString(val, "[REDACTED]")
-				}
-			}
-			arr[i] = val
-		case map[string]interface{}:
-			arr[i] = scrubMap(val, regexes...)
-		case []interface{}:
-			arr[i] = scrubSlice(val, regexes...)
-		}
-	}
-	return arr
-}
-// --- END PII Scrubber ---
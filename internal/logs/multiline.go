@@ -0,0 +1,209 @@
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gswarm-sidecar/internal/config"
+)
+
+const (
+	defaultMultilineMaxLines = 500
+	defaultMultilineTimeout  = time.Second
+
+	tracebackHeader = "Traceback (most recent call last):"
+)
+
+// defaultMultiline groups Python tracebacks, including the log record that
+// precedes one when it is written by logger.exception, into a single event.
+var defaultMultiline = config.MultilineConfig{
+	StartPattern:        `^(\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}|Traceback \(most recent call last\):)`,
+	ContinuationPattern: `^(\s|Traceback \(most recent call last\):|During handling of the above exception|The above exception was the direct cause|$)`,
+}
+
+// chainedExceptionRegex matches the lines that may follow the end of a
+// Python traceback when another exception was raised while handling it.
+var chainedExceptionRegex = regexp.MustCompile(`^(During handling of the above exception|The above exception was the direct cause|\s*$)`)
+
+// exceptionLineRegex matches the final "module.ExceptionType: message" line of
+// a Python traceback.
+var exceptionLineRegex = regexp.MustCompile(`^([A-Za-z_][\w.]*)(?::\s?(.*))?$`)
+
+// multilineRule is a compiled config.MultilineConfig.
+type multilineRule struct {
+	start    *regexp.Regexp
+	cont     *regexp.Regexp // nil: every non-start line continues the event
+	maxLines int
+	timeout  time.Duration
+}
+
+// multilineConfig returns the multiline settings of a log_files entry: its
+// own, else the global ones, else the default. Structured formats write one
+// record per line, so they get no default.
+func multilineConfig(fileCfg config.LogFileConfig, global *config.MultilineConfig) *config.MultilineConfig {
+	switch {
+	case fileCfg.Multiline != nil:
+		return fileCfg.Multiline
+	case global != nil:
+		return global
+	case fileCfg.Parser == parserJSON || fileCfg.Parser == parserLogfmt:
+		return &config.MultilineConfig{Disabled: true}
+	}
+	return nil
+}
+
+// compileMultiline compiles the rule for a file. It returns nil when lines
+// should be passed through one by one.
+func compileMultiline(cfg *config.MultilineConfig) (*multilineRule, error) {
+	if cfg == nil {
+		cfg = &defaultMultiline
+	}
+	if cfg.Disabled || cfg.StartPattern == "" {
+		return nil, nil
+	}
+	rule := &multilineRule{
+		maxLines: cfg.MaxLines,
+		timeout:  time.Duration(cfg.Timeout) * time.Second,
+	}
+	if rule.maxLines <= 0 {
+		rule.maxLines = defaultMultilineMaxLines
+	}
+	if rule.timeout <= 0 {
+		rule.timeout = defaultMultilineTimeout
+	}
+	var err error
+	if rule.start, err = regexp.Compile(cfg.StartPattern); err != nil {
+		return nil, fmt.Errorf("invalid multiline start_pattern: %w", err)
+	}
+	if cfg.ContinuationPattern != "" {
+		if rule.cont, err = regexp.Compile(cfg.ContinuationPattern); err != nil {
+			return nil, fmt.Errorf("invalid multiline continuation_pattern: %w", err)
+		}
+	}
+	return rule, nil
+}
+
+// multilineAssembler buffers the lines of the event being assembled. The
+// checkpoint of an assembled event is that of its last line, so a partly
+// assembled event is never committed.
+type multilineAssembler struct {
+	rule  *multilineRule
+	lines []string
+	last  fileCheckpoint
+	ended bool // the final line was seen; only a chained exception continues
+}
+
+func newMultilineAssembler(rule *multilineRule) *multilineAssembler {
+	return &multilineAssembler{rule: rule}
+}
+
+// Add feeds one physical line and returns the events it completes.
+func (a *multilineAssembler) Add(line tailLine) []tailLine {
+	if a.rule == nil {
+		return []tailLine{line}
+	}
+
+	var done []tailLine
+	switch {
+	case len(a.lines) == 0:
+		if !a.rule.start.MatchString(line.Text) {
+			return []tailLine{line}
+		}
+		a.append(line)
+	case a.ended && chainedExceptionRegex.MatchString(line.Text):
+		a.append(line)
+		a.ended = strings.TrimSpace(line.Text) == ""
+	case a.ended:
+		done = append(done, a.take())
+		return append(done, a.Add(line)...)
+	case a.continues(line.Text):
+		a.append(line)
+	case a.rule.start.MatchString(line.Text):
+		done = append(done, a.take())
+		a.append(line)
+	case len(a.lines) > 1:
+		// A line that neither continues nor starts an event ends the one in
+		// progress, e.g. "ValueError: ..." after a stack. It is held until
+		// the next line shows whether a chained exception follows.
+		a.append(line)
+		a.ended = true
+	default:
+		return []tailLine{a.take(), line}
+	}
+
+	if len(a.lines) >= a.rule.maxLines {
+		done = append(done, a.take())
+	}
+	return done
+}
+
+// Flush returns the event in progress, if any.
+func (a *multilineAssembler) Flush() (tailLine, bool) {
+	if len(a.lines) == 0 {
+		return tailLine{}, false
+	}
+	return a.take(), true
+}
+
+// Pending reports whether an event is being assembled.
+func (a *multilineAssembler) Pending() bool {
+	return len(a.lines) > 0
+}
+
+// Timeout is how long to wait for more lines before flushing.
+func (a *multilineAssembler) Timeout() time.Duration {
+	if a.rule == nil {
+		return defaultMultilineTimeout
+	}
+	return a.rule.timeout
+}
+
+func (a *multilineAssembler) continues(text string) bool {
+	if a.rule.cont == nil {
+		return !a.rule.start.MatchString(text)
+	}
+	return a.rule.cont.MatchString(text)
+}
+
+func (a *multilineAssembler) append(line tailLine) {
+	a.lines = append(a.lines, line.Text)
+	a.last = line.Checkpoint
+}
+
+func (a *multilineAssembler) take() tailLine {
+	lines := a.lines
+	// Blank lines held after the final line are not part of the event.
+	for len(lines) > 1 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	out := tailLine{Text: strings.Join(lines, "\n"), Checkpoint: a.last}
+	a.lines = a.lines[:0]
+	a.ended = false
+	return out
+}
+
+// parseTraceback extracts a Python traceback from text. It returns the
+// exception type, the exception message and the stack starting at the
+// "Traceback" header.
+func parseTraceback(text string) (excType, excMsg, stack string, ok bool) {
+	idx := strings.Index(text, tracebackHeader)
+	if idx == -1 {
+		return "", "", "", false
+	}
+	stack = strings.TrimRight(text[idx:], "\n")
+
+	lines := strings.Split(stack, "\n")
+	for i := len(lines) - 1; i > 0; i-- {
+		line := strings.TrimRight(lines[i], " \r")
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if m := exceptionLineRegex.FindStringSubmatch(line); m != nil {
+			return m[1], m[2], stack, true
+		}
+		break
+	}
+	return "", "", stack, true
+}
@@ -0,0 +1,177 @@
+package logs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gswarm-sidecar/internal/config"
+)
+
+// assemble feeds lines, numbered by their checkpoint offset, and returns the
+// assembled texts followed by the idle flush, if any.
+func assemble(t *testing.T, cfg *config.MultilineConfig, lines ...string) ([]string, []int64) {
+	t.Helper()
+	rule, err := compileMultiline(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newMultilineAssembler(rule)
+	var texts []string
+	var offsets []int64
+	collect := func(out ...tailLine) {
+		for _, l := range out {
+			texts = append(texts, l.Text)
+			offsets = append(offsets, l.Checkpoint.Offset)
+		}
+	}
+	for i, text := range lines {
+		collect(a.Add(tailLine{Text: text, Checkpoint: fileCheckpoint{Offset: int64(i + 1)}})...)
+	}
+	if out, ok := a.Flush(); ok {
+		collect(out)
+	}
+	if a.Pending() {
+		t.Error("assembler still pending after Flush")
+	}
+	return texts, offsets
+}
+
+func TestMultilineDefaultGroupsTracebacks(t *testing.T) {
+	record := "2025-01-02 03:04:05,678 - ERROR - trainer - Step failed"
+	texts, offsets := assemble(t, nil,
+		"2025-01-02 03:04:04,000 - INFO - trainer - Step 1",
+		record,
+		"Traceback (most recent call last):",
+		`  File "train.py", line 10, in step`,
+		"    loss.backward()",
+		"RuntimeError: CUDA out of memory",
+		"plain line",
+	)
+
+	want := []string{
+		"2025-01-02 03:04:04,000 - INFO - trainer - Step 1",
+		strings.Join([]string{record, "Traceback (most recent call last):", `  File "train.py", line 10, in step`,
+			"    loss.backward()", "RuntimeError: CUDA out of memory"}, "\n"),
+		"plain line",
+	}
+	if !reflect.DeepEqual(texts, want) {
+		t.Fatalf("assembled %q, want %q", texts, want)
+	}
+	// An event is checkpointed at its last line, never in the middle.
+	if !reflect.DeepEqual(offsets, []int64{1, 6, 7}) {
+		t.Errorf("checkpoints = %v, want [1 6 7]", offsets)
+	}
+}
+
+func TestMultilineChainedExceptions(t *testing.T) {
+	texts, _ := assemble(t, nil,
+		"Traceback (most recent call last):",
+		"  File \"a.py\", line 1",
+		"KeyError: 'x'",
+		"",
+		"During handling of the above exception, another exception occurred:",
+		"",
+		"Traceback (most recent call last):",
+		"  File \"b.py\", line 2",
+		"ValueError: bad",
+	)
+	if len(texts) != 1 || !strings.HasPrefix(texts[0], "Traceback") || !strings.HasSuffix(texts[0], "ValueError: bad") {
+		t.Fatalf("assembled %q, want one event", texts)
+	}
+	if excType, _, _, _ := parseTraceback(texts[0]); excType != "ValueError" {
+		t.Errorf("exception type = %q, want the last one raised", excType)
+	}
+}
+
+func TestMultilineHoldsEndedEventForChain(t *testing.T) {
+	rule, _ := compileMultiline(nil)
+	a := newMultilineAssembler(rule)
+	a.Add(tailLine{Text: "Traceback (most recent call last):"})
+	a.Add(tailLine{Text: "  File \"a.py\""})
+	if out := a.Add(tailLine{Text: "KeyError: 'x'"}); len(out) != 0 {
+		t.Fatalf("emitted %q before the next line", out)
+	}
+	a.Add(tailLine{Text: ""})
+	out := a.Add(tailLine{Text: "next record"})
+	if len(out) != 2 || out[0].Text != "Traceback (most recent call last):\n  File \"a.py\"\nKeyError: 'x'" || out[1].Text != "next record" {
+		t.Errorf("emitted %q", out)
+	}
+}
+
+func TestMultilineIdleFlush(t *testing.T) {
+	rule, err := compileMultiline(&config.MultilineConfig{StartPattern: `^BEGIN`, Timeout: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newMultilineAssembler(rule)
+	if a.Timeout() != 3*time.Second {
+		t.Errorf("timeout = %v, want 3s", a.Timeout())
+	}
+	for i, text := range []string{"BEGIN job", "  step 1", "  step 2"} {
+		if out := a.Add(tailLine{Text: text, Checkpoint: fileCheckpoint{Offset: int64(i)}}); len(out) != 0 {
+			t.Fatalf("emitted %q before the event ended", out)
+		}
+	}
+	if !a.Pending() {
+		t.Fatal("no event pending")
+	}
+	// No more lines arrive: the monitor flushes after Timeout.
+	out, ok := a.Flush()
+	if !ok || out.Text != "BEGIN job\n  step 1\n  step 2" || out.Checkpoint.Offset != 2 {
+		t.Errorf("flushed %+v, %v", out, ok)
+	}
+	if _, ok := a.Flush(); ok {
+		t.Error("second flush returned an event")
+	}
+}
+
+func TestMultilineMaxLines(t *testing.T) {
+	texts, _ := assemble(t, &config.MultilineConfig{StartPattern: `^BEGIN`, MaxLines: 2},
+		"BEGIN", "a", "b", "c")
+	want := []string{"BEGIN\na", "b", "c"}
+	if !reflect.DeepEqual(texts, want) {
+		t.Errorf("assembled %q, want %q", texts, want)
+	}
+}
+
+func TestMultilineDisabled(t *testing.T) {
+	lines := []string{"Traceback (most recent call last):", "  File \"a.py\"", "KeyError: 'x'"}
+	texts, _ := assemble(t, &config.MultilineConfig{Disabled: true}, lines...)
+	if !reflect.DeepEqual(texts, lines) {
+		t.Errorf("assembled %q, want lines passed through", texts)
+	}
+	if cfg := multilineConfig(config.LogFileConfig{Parser: parserJSON}, nil); cfg == nil || !cfg.Disabled {
+		t.Errorf("json files get multiline config %+v, want disabled", cfg)
+	}
+}
+
+func TestCompileMultilineRejectsBadPatterns(t *testing.T) {
+	for _, cfg := range []config.MultilineConfig{
+		{StartPattern: "("},
+		{StartPattern: "^x", ContinuationPattern: "["},
+	} {
+		if _, err := compileMultiline(&cfg); err == nil {
+			t.Errorf("%+v compiled", cfg)
+		}
+	}
+}
+
+func TestParseTraceback(t *testing.T) {
+	for _, tc := range []struct {
+		text, excType, excMsg string
+	}{
+		{"Traceback (most recent call last):\n  File \"a.py\"\ntorch.cuda.OutOfMemoryError: CUDA out of memory", "torch.cuda.OutOfMemoryError", "CUDA out of memory"},
+		{"Traceback (most recent call last):\n  File \"a.py\"\nKeyboardInterrupt", "KeyboardInterrupt", ""},
+		{"Traceback (most recent call last):\n  File \"a.py\"\n", "", ""},
+	} {
+		excType, excMsg, stack, ok := parseTraceback(tc.text)
+		if !ok || excType != tc.excType || excMsg != tc.excMsg || !strings.HasPrefix(stack, tracebackHeader) {
+			t.Errorf("parseTraceback(%q) = %q, %q, %v", tc.text, excType, excMsg, ok)
+		}
+	}
+	if _, _, _, ok := parseTraceback("no traceback here"); ok {
+		t.Error("found a traceback in plain text")
+	}
+}
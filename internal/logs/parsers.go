@@ -0,0 +1,327 @@
+package logs
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gswarm-sidecar/internal/config"
+)
+
+// Parser names accepted in log_files entries.
+const (
+	parserAuto   = "auto"
+	parserSwarm  = "swarm"
+	parserYarn   = "yarn"
+	parserWandb  = "wandb"
+	parserJSON   = "json"
+	parserLogfmt = "logfmt"
+)
+
+// logParser turns the assembled records of one log format into events.
+type logParser struct {
+	Name string
+	// Parse returns the event for a record and the rule that matched it, if
+	// any. A nil event means the record is skipped.
+	Parse func(line string, cfg *config.Config, rules []*extractionRule) (*MetricEvent, *extractionRule)
+	// Rules are the built-in extraction rules for the format.
+	Rules []*extractionRule
+	// PathFields returns details derived from the file path that are added to
+	// every event of the file.
+	PathFields func(path string) map[string]interface{}
+}
+
+var logParsers = map[string]*logParser{
+	parserSwarm:  {Name: parserSwarm, Parse: parseSwarmLogLine, Rules: swarmRules},
+	parserYarn:   {Name: parserYarn, Parse: parseYarnLogLine, Rules: yarnRules},
+	parserWandb:  {Name: parserWandb, Parse: parseWandbLogLine, Rules: wandbRules, PathFields: wandbPathFields},
+	parserJSON:   {Name: parserJSON, Parse: parseJSONLogLine, Rules: swarmRules},
+	parserLogfmt: {Name: parserLogfmt, Parse: parseLogfmtLogLine, Rules: swarmRules},
+}
+
+// selectParser returns the parser for path. With "auto" (or no name) the
+// format is guessed from the legacy logs.*_log_path settings and the file name.
+func selectParser(name, path string, cfg *config.Config) (*logParser, error) {
+	if name != "" && name != parserAuto {
+		parser, ok := logParsers[name]
+		if !ok {
+			return logParsers[parserSwarm], fmt.Errorf("unknown parser %q (want auto, %s, %s, %s, %s or %s)", name, parserSwarm, parserYarn, parserWandb, parserJSON, parserLogfmt)
+		}
+		return parser, nil
+	}
+
+	switch {
+	case samePath(path, cfg.Logs.YarnLogPath):
+		return logParsers[parserYarn], nil
+	case samePath(path, cfg.Logs.WandbLogPath):
+		return logParsers[parserWandb], nil
+	case samePath(path, cfg.Logs.SwarmLogPath):
+		return logParsers[parserSwarm], nil
+	}
+	slashed := filepath.ToSlash(path)
+	base := strings.ToLower(filepath.Base(path))
+	switch {
+	case strings.Contains(base, "yarn"):
+		return logParsers[parserYarn], nil
+	case strings.Contains(slashed, "/wandb/") && strings.HasPrefix(base, "debug"):
+		return logParsers[parserWandb], nil
+	case isJSONLines(base):
+		return logParsers[parserJSON], nil
+	default:
+		return logParsers[parserSwarm], nil
+	}
+}
+
+func samePath(path, configured string) bool {
+	if configured == "" {
+		return false
+	}
+	abs, err := filepath.Abs(configured)
+	return err == nil && abs == path
+}
+
+// ansiRegex matches terminal color and cursor escape sequences.
+var ansiRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+func stripANSI(s string) string {
+	return ansiRegex.ReplaceAllString(s, "")
+}
+
+// ruleEvent runs rules against texts and builds the event of the first match.
+// A nil rule means nothing matched; a nil event with a rule means it was dropped.
+func ruleEvent(rules []*extractionRule, cfg *config.Config, ts time.Time, details map[string]interface{}, texts ...string) (*MetricEvent, *extractionRule) {
+	rule := applyRules(rules, details, texts...)
+	if rule == nil || rule.Drop {
+		return nil, rule
+	}
+	return &MetricEvent{
+		NodeID:    cfg.NodeID,
+		Timestamp: ts,
+		EventType: rule.EventType,
+		Details:   details,
+	}, rule
+}
+
+// rawEvent handles a record that does not match its file's format.
+func rawEvent(line string, cfg *config.Config, rules []*extractionRule) (*MetricEvent, *extractionRule) {
+	details := map[string]interface{}{
+		"raw_line": line,
+	}
+	if event, rule := ruleEvent(rules, cfg, time.Now(), details, line); rule != nil {
+		return event, rule
+	}
+	return &MetricEvent{
+		NodeID:    cfg.NodeID,
+		Timestamp: time.Now(),
+		EventType: "raw",
+		Details:   details,
+	}, nil
+}
+
+// --- yarn (modal-login Next.js web UI) ---
+
+// yarnMarkers are the status symbols Next.js prefixes its output with.
+const yarnMarkers = "✓○⨯⚠▲ "
+
+// yarnRules recognise the modal-login web UI output written to yarn.log.
+var yarnRules = []*extractionRule{
+	{
+		Name:      "yarn_auth_request",
+		EventType: "auth_event",
+		Pattern:   yarnAuthRegex,
+		Extract:   yarnAuthFields,
+	},
+	{
+		Name:      "yarn_http_request",
+		EventType: "http_request",
+		Pattern:   regexp.MustCompile(`^(?P<method>GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS)\s+(?P<path>\S+)\s+(?P<status>\d{3})\s+in\s+(?P<duration_ms>\d+(?:\.\d+)?m?s)`),
+		Fields:    map[string]string{"status": fieldInt, "duration_ms": fieldDuration},
+	},
+	{
+		Name:      "yarn_ready",
+		EventType: "server_event",
+		Pattern:   regexp.MustCompile(`^Ready in (?P<startup_ms>\d+(?:\.\d+)?m?s)`),
+		Fields:    map[string]string{"startup_ms": fieldDuration},
+		Details:   map[string]interface{}{"action": "ready"},
+	},
+	{
+		Name:      "yarn_compiled",
+		EventType: "server_event",
+		Pattern:   regexp.MustCompile(`^Compiled(?: (?P<route>/\S*))? in (?P<duration_ms>\d+(?:\.\d+)?m?s)(?: \((?P<modules>\d+) modules\))?`),
+		Fields:    map[string]string{"duration_ms": fieldDuration, "modules": fieldInt},
+		Details:   map[string]interface{}{"action": "compiled"},
+	},
+	{
+		Name:      "yarn_exit",
+		EventType: "error",
+		Pattern:   regexp.MustCompile(`(?i)^error Command failed with exit code (?P<exit_code>\d+)`),
+		Fields:    map[string]string{"exit_code": fieldInt},
+		Details:   map[string]interface{}{"code": "EXIT"},
+	},
+}
+
+var yarnAuthRegex = regexp.MustCompile(`^(?:GET|POST|PUT|PATCH|DELETE)\s+/api/(get-user-from-email|get-api-key|register-peer|login|logout|auth\S*?)(?:\?\S*)?\s+(\d{3})\s+in\s+(\d+(?:\.\d+)?m?s)`)
+
+// yarnAuthFields describes a request to one of the login API routes.
+func yarnAuthFields(msg string) map[string]interface{} {
+	m := yarnAuthRegex.FindStringSubmatch(msg)
+	if m == nil {
+		return nil
+	}
+	fields := map[string]interface{}{
+		"action":      strings.ReplaceAll(m[1], "-", "_"),
+		"http_status": convertField(m[2], fieldInt),
+		"duration_ms": convertField(m[3], fieldDuration),
+		"status":      "success",
+	}
+	if m[2] >= "400" {
+		fields["status"] = "failure"
+	}
+	return fields
+}
+
+var (
+	yarnErrorRegex   = regexp.MustCompile(`(?i)^(?:error\b|[\w.]*Error\b|unhandled\b|failed to compile)`)
+	yarnWarningRegex = regexp.MustCompile(`(?i)^warn(?:ing)?\b`)
+)
+
+// parseYarnLogLine parses a line of yarn / Next.js output. These lines carry
+// no timestamp or level, so the level is inferred from Next.js markers and
+// yarn prefixes.
+func parseYarnLogLine(line string, cfg *config.Config, rules []*extractionRule) (*MetricEvent, *extractionRule) {
+	text := strings.TrimSpace(stripANSI(line))
+	if text == "" {
+		return nil, nil
+	}
+	msg := strings.TrimLeft(text, yarnMarkers)
+	details := map[string]interface{}{
+		"message": msg,
+	}
+	level := "info"
+	switch {
+	case strings.HasPrefix(text, "⨯") || yarnErrorRegex.MatchString(msg):
+		level = "error"
+	case strings.HasPrefix(text, "⚠") || yarnWarningRegex.MatchString(msg):
+		level = "warning"
+	}
+	ts := time.Now()
+	if event, rule := ruleEvent(rules, cfg, ts, details, msg); rule != nil {
+		return withLevel(event, level), rule
+	}
+	return &MetricEvent{
+		NodeID:    cfg.NodeID,
+		Timestamp: ts,
+		EventType: level,
+		Details:   details,
+		Level:     level,
+	}, nil
+}
+
+// --- wandb debug.log / debug-internal.log ---
+
+// wandbLineRegex matches the Python logging format of wandb's debug logs:
+// "2024-06-01 12:00:00,123 INFO    HandlerThread:1234 [handler.py:handle_request():146] message".
+var wandbLineRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2},\d{3})\s+([A-Z]+)\s+(\S+?):(\d+)\s+\[([^\]]*)\]\s?(.*)$`)
+
+// wandbRunDirRegex extracts the run ID from a wandb run directory name.
+var wandbRunDirRegex = regexp.MustCompile(`run-(\d{8}_\d{6})-([a-z0-9]+)`)
+
+// wandbRules recognise run lifecycle and sync failures in wandb's logs.
+var wandbRules = []*extractionRule{
+	{
+		Name:      "wandb_run_started",
+		EventType: "wandb_run",
+		Pattern:   regexp.MustCompile(`(?i)logging (?:user|internal) logs to \S*?run-(?P<run_started_at>\d{8}_\d{6})-(?P<run_id>[a-z0-9]+)`),
+		Details:   map[string]interface{}{"action": "run_started"},
+	},
+	{
+		Name:      "wandb_run_finished",
+		EventType: "wandb_run",
+		Pattern:   regexp.MustCompile(`(?i)(?:send(?:ing)?:? exit|run exited|exit(?:ed with)?(?: exit)? code|exit_code)[:= ]*(?P<exit_code>-?\d+)?`),
+		Fields:    map[string]string{"exit_code": fieldInt},
+		Details:   map[string]interface{}{"action": "run_finished"},
+	},
+	{
+		Name:      "wandb_sync_error",
+		EventType: "wandb_sync_error",
+		Pattern:   regexp.MustCompile(`(?i)(?:filestream|file_stream|upload|sync|graphql|network|api\.wandb\.ai)[^\n]*?(?:error|failed|failure|exception|timed out)(?:[^\n]*?(?:status(?:_code| code)?|http)[:= ]*(?P<status_code>[1-5]\d\d))?`),
+		Fields:    map[string]string{"status_code": fieldInt},
+	},
+}
+
+// wandbPathFields tags events from files inside a run directory with its ID.
+func wandbPathFields(path string) map[string]interface{} {
+	m := wandbRunDirRegex.FindStringSubmatch(filepath.ToSlash(path))
+	if m == nil {
+		return nil
+	}
+	return map[string]interface{}{"run_id": m[2]}
+}
+
+// parseWandbLogLine parses wandb's debug.log and debug-internal.log, in
+// either the classic Python logging format or the JSON lines written by
+// wandb-core.
+func parseWandbLogLine(line string, cfg *config.Config, rules []*extractionRule) (*MetricEvent, *extractionRule) {
+	first, rest, _ := strings.Cut(line, "\n")
+	if strings.HasPrefix(first, "{") {
+		if event, rule, ok := parseWandbJSON(first, cfg, rules); ok {
+			return event, rule
+		}
+	}
+
+	m := wandbLineRegex.FindStringSubmatch(first)
+	if m == nil {
+		if event, rule, ok := tracebackEvent(line, time.Now(), "", "", cfg, rules); ok {
+			return event, rule
+		}
+		return rawEvent(line, cfg, rules)
+	}
+	ts, err := time.Parse("2006-01-02 15:04:05,000", m[1])
+	if err != nil {
+		ts = time.Now()
+	}
+	level, thread, source, msg := normalizeLevel(m[2]), m[3], m[5], strings.TrimSpace(m[6])
+
+	if event, rule, ok := tracebackEvent(rest, ts, source, msg, cfg, rules); ok {
+		if event != nil {
+			event.Details["thread"] = thread
+		}
+		return withLevel(event, level), rule
+	}
+	if rest != "" {
+		msg += "\n" + rest
+	}
+
+	details := map[string]interface{}{
+		"logger":  source,
+		"thread":  thread,
+		"message": msg,
+	}
+	if event, rule := ruleEvent(rules, cfg, ts, details, msg); rule != nil {
+		return withLevel(event, level), rule
+	}
+	return &MetricEvent{
+		NodeID:    cfg.NodeID,
+		Timestamp: ts,
+		EventType: level,
+		Details:   details,
+		Level:     level,
+	}, nil
+}
+
+// parseWandbJSON parses a wandb-core JSON log record
+// ({"time": ..., "level": ..., "msg": ..., ...}). ok is false when the line
+// is not such a record.
+func parseWandbJSON(line string, cfg *config.Config, rules []*extractionRule) (*MetricEvent, *extractionRule, bool) {
+	record, ok := parseJSONRecord(line)
+	if !ok {
+		return nil, nil, false
+	}
+	if _, ok := record["msg"].(string); !ok {
+		return nil, nil, false
+	}
+	event, rule := structuredEvent(record, cfg, rules)
+	return event, rule, true
+}
@@ -0,0 +1,112 @@
+package logs
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gswarm-sidecar/internal/config"
+)
+
+func TestSelectParser(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.Logs.YarnLogPath = filepath.Join(dir, "web.log")
+
+	for _, tc := range []struct {
+		name, path, want string
+	}{
+		{"", filepath.Join(dir, "web.log"), parserYarn},
+		{"auto", filepath.Join(dir, "yarn.log"), parserYarn},
+		{"", filepath.Join(dir, "wandb", "run-20250101_000000-abc", "logs", "debug-internal.log"), parserWandb},
+		{"", filepath.Join(dir, "events.jsonl"), parserJSON},
+		{"", filepath.Join(dir, "swarm_launcher.log"), parserSwarm},
+		{parserLogfmt, filepath.Join(dir, "yarn.log"), parserLogfmt},
+	} {
+		parser, err := selectParser(tc.name, tc.path, cfg)
+		if err != nil || parser.Name != tc.want {
+			t.Errorf("selectParser(%q, %s) = %s, %v; want %s", tc.name, tc.path, parser.Name, err, tc.want)
+		}
+	}
+	if parser, err := selectParser("syslog", "x.log", cfg); err == nil || parser.Name != parserSwarm {
+		t.Errorf("unknown parser: %s, %v", parser.Name, err)
+	}
+}
+
+func TestYarnParser(t *testing.T) {
+	cfg := &config.Config{}
+	for _, tc := range []struct {
+		line, eventType, level string
+		want                   map[string]interface{}
+	}{
+		{"\x1b[32m ✓\x1b[39m Ready in 2.1s", "server_event", "info",
+			map[string]interface{}{"action": "ready", "startup_ms": 2100.0}},
+		{" ✓ Compiled /api/login in 350ms (512 modules)", "server_event", "info",
+			map[string]interface{}{"action": "compiled", "route": "/api/login", "duration_ms": 350.0, "modules": int64(512)}},
+		{"POST /api/get-user-from-email 200 in 45ms", "auth_event", "info",
+			map[string]interface{}{"action": "get_user_from_email", "http_status": int64(200), "duration_ms": 45.0, "status": "success"}},
+		{"POST /api/register-peer 500 in 1.2s", "auth_event", "info",
+			map[string]interface{}{"action": "register_peer", "http_status": int64(500), "status": "failure"}},
+		{"GET /favicon.ico 404 in 3ms", "http_request", "info",
+			map[string]interface{}{"method": "GET", "path": "/favicon.ico", "status": int64(404), "duration_ms": 3.0}},
+		{"error Command failed with exit code 137.", "error", "error",
+			map[string]interface{}{"code": "EXIT", "exit_code": int64(137)}},
+		{" ⚠ Fast Refresh had to perform a full reload", "warning", "warning", nil},
+		{" ⨯ TypeError: fetch failed", "error", "error", map[string]interface{}{"message": "TypeError: fetch failed"}},
+	} {
+		event, _ := parseYarnLogLine(tc.line, cfg, yarnRules)
+		if event == nil || event.EventType != tc.eventType || event.Level != tc.level {
+			t.Errorf("%q parsed as %+v, want %s/%s", tc.line, event, tc.eventType, tc.level)
+			continue
+		}
+		for k, v := range tc.want {
+			if got := event.Details[k]; !reflect.DeepEqual(got, v) {
+				t.Errorf("%q: %s = %#v, want %#v", tc.line, k, got, v)
+			}
+		}
+	}
+	if event, _ := parseYarnLogLine("   ", cfg, yarnRules); event != nil {
+		t.Errorf("blank line produced %+v", event)
+	}
+}
+
+func TestWandbParser(t *testing.T) {
+	cfg := &config.Config{}
+	line := "2025-06-01 12:00:00,123 INFO    HandlerThread:1234 [handler.py:handle_request():146] Logging internal logs to /root/wandb/run-20250601_120000-x1y2z3/logs/debug-internal.log"
+	event, rule := parseWandbLogLine(line, cfg, wandbRules)
+	if rule == nil || rule.Name != "wandb_run_started" || event.EventType != "wandb_run" || event.Level != "info" {
+		t.Fatalf("parsed as %+v by %v", event, rule)
+	}
+	if event.Details["run_id"] != "x1y2z3" || event.Details["thread"] != "HandlerThread" || event.Details["logger"] != "handler.py:handle_request():146" {
+		t.Errorf("details = %v", event.Details)
+	}
+	if want := time.Date(2025, 6, 1, 12, 0, 0, 123e6, time.UTC); !event.Timestamp.Equal(want) {
+		t.Errorf("timestamp = %v", event.Timestamp)
+	}
+
+	line = "2025-06-01 12:05:00,000 ERROR   FileStreamThread:99 [file_stream.py:_post():120] file_stream upload failed, status code: 503"
+	event, rule = parseWandbLogLine(line, cfg, wandbRules)
+	if rule == nil || rule.Name != "wandb_sync_error" || event.Details["status_code"] != int64(503) || event.Level != "error" {
+		t.Errorf("sync error parsed as %+v by %v", event, rule)
+	}
+
+	line = `{"time":"2025-06-01T12:10:00Z","level":"INFO","msg":"sending exit","exit_code":0}`
+	event, rule = parseWandbLogLine(line, cfg, wandbRules)
+	if rule == nil || rule.Name != "wandb_run_finished" || event.EventType != "wandb_run" {
+		t.Errorf("wandb-core record parsed as %+v by %v", event, rule)
+	}
+
+	event, _ = parseWandbLogLine("not a wandb line", cfg, wandbRules)
+	if event == nil || event.EventType != "raw" {
+		t.Errorf("unknown line parsed as %+v", event)
+	}
+
+	fields := wandbPathFields("/root/wandb/run-20250601_120000-x1y2z3/files/output.log")
+	if fields["run_id"] != "x1y2z3" {
+		t.Errorf("path fields = %v", fields)
+	}
+	if wandbPathFields("/root/wandb/debug.log") != nil {
+		t.Error("path fields outside a run directory")
+	}
+}
@@ -0,0 +1,314 @@
+package logs
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gswarm-sidecar/internal/config"
+)
+
+// Field types for extraction rule capture groups.
+const (
+	fieldString   = "string"
+	fieldInt      = "int"
+	fieldFloat    = "float"
+	fieldBool     = "bool"
+	fieldDuration = "duration" // e.g. "2.1s" or "450ms", sent as milliseconds
+)
+
+// Rule actions.
+const (
+	ruleActionKeep = "keep"
+	ruleActionDrop = "drop"
+)
+
+// extractionRule turns a log message it matches into a typed event. Named
+// capture groups in Pattern become Details fields, converted according to
+// Fields (strings by default); groups that did not participate are omitted.
+type extractionRule struct {
+	Name      string
+	EventType string
+	Pattern   *regexp.Regexp
+	Fields    map[string]string
+	Details   map[string]interface{}                  // constant details added to every match
+	Extract   func(msg string) map[string]interface{} // optional, replaces the capture groups
+	Files     []string                                // glob patterns of the files the rule applies to, empty means all
+	Drop      bool                                    // matching messages produce no event
+}
+
+// swarmRules recognise the messages RL-Swarm (hivemind_exp and genrl) and the
+// Hugging Face trainer write to the swarm log. The first matching rule wins,
+// so more specific patterns come first.
+var swarmRules = []*extractionRule{
+	{
+		Name:      "stage_finished",
+		EventType: "round_event",
+		Pattern:   regexp.MustCompile(`(?i)finished training round:?\s*(?P<round>\d+),?\s*stage:?\s*(?P<stage>\d+)`),
+		Fields:    map[string]string{"round": fieldInt, "stage": fieldInt},
+		Details:   map[string]interface{}{"action": "stage_finished"},
+	},
+	{
+		Name:      "stage_started",
+		EventType: "round_event",
+		Pattern:   regexp.MustCompile(`(?i)training round:?\s*(?P<round>\d+),?\s*stage:?\s*(?P<stage>\d+)`),
+		Fields:    map[string]string{"round": fieldInt, "stage": fieldInt},
+		Details:   map[string]interface{}{"action": "stage_started"},
+	},
+	{
+		Name:      "round_waiting",
+		EventType: "round_event",
+		Pattern:   regexp.MustCompile(`(?i)already finished round:?\s*(?P<round>\d+)`),
+		Fields:    map[string]string{"round": fieldInt},
+		Details:   map[string]interface{}{"action": "round_waiting"},
+	},
+	{
+		Name:      "round_finished",
+		EventType: "round_event",
+		Pattern:   regexp.MustCompile(`(?i)(?:finished|completed) round:?\s*(?P<round>\d+)`),
+		Fields:    map[string]string{"round": fieldInt},
+		Details:   map[string]interface{}{"action": "round_finished"},
+	},
+	{
+		Name:      "round_started",
+		EventType: "round_event",
+		Pattern:   regexp.MustCompile(`(?i)(?:starting|joining) round:?\s*(?P<round>\d+)(?:/(?P<max_round>\d+))?(?:.*?stage:?\s*(?P<stage>\d+))?`),
+		Fields:    map[string]string{"round": fieldInt, "max_round": fieldInt, "stage": fieldInt},
+		Details:   map[string]interface{}{"action": "round_started"},
+	},
+	{
+		Name:      "reward_submitted",
+		EventType: "reward_event",
+		Pattern:   regexp.MustCompile(`(?i)submit(?:ted|ting)\s+(?:the\s+)?rewards?\b:?\s*(?P<reward>-?\d+(?:\.\d+)?)?(?:.*?round:?\s*(?P<round>\d+))?(?:.*?stage:?\s*(?P<stage>\d+))?`),
+		Fields:    map[string]string{"reward": fieldFloat, "round": fieldInt, "stage": fieldInt},
+		Details:   map[string]interface{}{"action": "reward_submitted"},
+	},
+	{
+		Name:      "out_of_memory",
+		EventType: "error",
+		Pattern:   regexp.MustCompile(`(?i)(?:OutOfMemoryError|out of memory)(?:.*?tried to allocate (?P<requested>\d+(?:\.\d+)?\s?[KMGT]i?B))?`),
+		Details:   map[string]interface{}{"code": "OOM"},
+	},
+	{
+		Name:      "checkpoint_saved",
+		EventType: "checkpoint",
+		Pattern:   regexp.MustCompile(`(?i)sav(?:ing|ed)\s+(?:model\s+)?checkpoint(?:\s+to)?:?\s*(?P<path>\S+)?`),
+		Details:   map[string]interface{}{"action": "saved"},
+	},
+	{
+		Name:      "peer_dropped",
+		EventType: "peer_event",
+		Pattern:   regexp.MustCompile(`(?i)peer\s+(?P<peer_id>[\w.:/-]+)\s+(?:disconnected|dropped|left|timed out|is unreachable)`),
+		Details:   map[string]interface{}{"action": "drop"},
+	},
+	{
+		Name:      "peer_lost",
+		EventType: "peer_event",
+		Pattern:   regexp.MustCompile(`(?i)(?:lost connection to|dropping|disconnected from)\s+peer:?\s+(?P<peer_id>[\w.:/-]+)`),
+		Details:   map[string]interface{}{"action": "drop"},
+	},
+	{
+		Name:      "training_metrics",
+		EventType: "training_progress",
+		Pattern:   regexp.MustCompile(`(?i)['"]?\b(?:loss|rewards?)['"]?\s*[:=]\s*-?\d`),
+		Extract:   numericPairs,
+	},
+}
+
+// numericPairRegex matches key: value or key=value pairs with numeric values,
+// as in the trainer's {'loss': 0.0123, 'reward': 0.5, 'epoch': 0.02} dicts.
+var numericPairRegex = regexp.MustCompile(`['"]?([A-Za-z_][\w/.-]*)['"]?\s*[:=]\s*(-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?)\b`)
+
+// numericPairs returns every numeric key/value pair in msg.
+func numericPairs(msg string) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for _, m := range numericPairRegex.FindAllStringSubmatch(msg, -1) {
+		if v, err := strconv.ParseFloat(m[2], 64); err == nil {
+			fields[m[1]] = v
+		}
+	}
+	return fields
+}
+
+// match returns the typed fields extracted from msg.
+func (r *extractionRule) match(msg string) (map[string]interface{}, bool) {
+	m := r.Pattern.FindStringSubmatch(msg)
+	if m == nil {
+		return nil, false
+	}
+
+	fields := make(map[string]interface{}, len(r.Details))
+	for k, v := range r.Details {
+		fields[k] = v
+	}
+	if r.Extract != nil {
+		for k, v := range r.Extract(msg) {
+			fields[k] = v
+		}
+		return fields, true
+	}
+	for i, name := range r.Pattern.SubexpNames() {
+		if name == "" || m[i] == "" {
+			continue
+		}
+		fields[name] = convertField(strings.TrimSpace(m[i]), r.Fields[name])
+	}
+	return fields, true
+}
+
+// compileRules compiles the user-defined rules from config. Invalid rules are
+// skipped and reported in the returned errors.
+func compileRules(cfgs []config.LogRuleConfig) ([]*extractionRule, []error) {
+	var rules []*extractionRule
+	var errs []error
+	for i, cfg := range cfgs {
+		name := cfg.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i+1)
+		}
+		rule, err := compileRule(name, cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("log rule %q: %w", name, err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules, errs
+}
+
+func compileRule(name string, cfg config.LogRuleConfig) (*extractionRule, error) {
+	rule := &extractionRule{
+		Name:      name,
+		EventType: cfg.EventType,
+		Fields:    cfg.Fields,
+		Files:     cfg.Files,
+	}
+
+	switch strings.ToLower(cfg.Action) {
+	case "", ruleActionKeep:
+		if cfg.EventType == "" {
+			return nil, fmt.Errorf("event_type is required")
+		}
+	case ruleActionDrop:
+		rule.Drop = true
+	default:
+		return nil, fmt.Errorf("unknown action %q (want %s or %s)", cfg.Action, ruleActionKeep, ruleActionDrop)
+	}
+
+	if cfg.Pattern == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+	pattern, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	rule.Pattern = pattern
+
+	groups := make(map[string]bool)
+	for _, group := range pattern.SubexpNames() {
+		groups[group] = true
+	}
+	for field, kind := range cfg.Fields {
+		if !groups[field] {
+			return nil, fmt.Errorf("field %q is not a named capture group in pattern", field)
+		}
+		switch kind {
+		case fieldString, fieldInt, fieldFloat, fieldBool, fieldDuration:
+		default:
+			return nil, fmt.Errorf("field %q has unknown type %q", field, kind)
+		}
+	}
+	for _, glob := range cfg.Files {
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid file pattern %q: %w", glob, err)
+		}
+	}
+	return rule, nil
+}
+
+// appliesTo reports whether the rule's file selectors match path. Selectors
+// without a separator match the base name, others the whole path, with "**"
+// matching any number of directories.
+func (r *extractionRule) appliesTo(path string) bool {
+	if len(r.Files) == 0 {
+		return true
+	}
+	for _, glob := range r.Files {
+		if !strings.ContainsRune(filepath.ToSlash(glob), '/') {
+			if ok, _ := filepath.Match(glob, filepath.Base(path)); ok {
+				return true
+			}
+			continue
+		}
+		if abs, err := filepath.Abs(glob); err == nil {
+			glob = abs
+		}
+		if matchSegments(strings.Split(filepath.ToSlash(glob), "/"), strings.Split(filepath.ToSlash(path), "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// rulesForFile returns the user rules that apply to path followed by the
+// built-in rules of the file's parser.
+func rulesForFile(userRules []*extractionRule, path string, builtin []*extractionRule) []*extractionRule {
+	rules := make([]*extractionRule, 0, len(userRules)+len(builtin))
+	for _, rule := range userRules {
+		if rule.appliesTo(path) {
+			rules = append(rules, rule)
+		}
+	}
+	return append(rules, builtin...)
+}
+
+// convertField converts a captured value, keeping the string when it does not
+// parse as the requested type.
+func convertField(value, kind string) interface{} {
+	switch kind {
+	case fieldInt:
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return v
+		}
+	case fieldFloat:
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			return v
+		}
+	case fieldBool:
+		if v, err := strconv.ParseBool(strings.ToLower(value)); err == nil {
+			return v
+		}
+	case fieldDuration:
+		if v, err := time.ParseDuration(strings.ReplaceAll(value, " ", "")); err == nil {
+			return float64(v) / float64(time.Millisecond)
+		}
+	}
+	return value
+}
+
+// applyRules runs the first rule matching any of texts. It merges the
+// extracted fields into details and returns the rule, or nil if none matched.
+// Nothing is merged for drop rules.
+func applyRules(rules []*extractionRule, details map[string]interface{}, texts ...string) *extractionRule {
+	for _, rule := range rules {
+		for _, text := range texts {
+			if text == "" {
+				continue
+			}
+			fields, ok := rule.match(text)
+			if !ok {
+				continue
+			}
+			if !rule.Drop {
+				for k, v := range fields {
+					details[k] = v
+				}
+			}
+			return rule
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package logs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gswarm-sidecar/internal/config"
+)
+
+func swarmLine(level, logger, msg string) string {
+	return "2025-01-02 03:04:05,678 - " + level + " - " + logger + " - " + msg
+}
+
+func TestSwarmRules(t *testing.T) {
+	cfg := &config.Config{NodeID: "node-1"}
+	for _, tc := range []struct {
+		msg, rule, eventType string
+		want                 map[string]interface{}
+	}{
+		{"Starting round: 42/1000000.", "round_started", "round_event",
+			map[string]interface{}{"action": "round_started", "round": int64(42), "max_round": int64(1000000)}},
+		{"Joining round: 7 stage: 2", "round_started", "round_event",
+			map[string]interface{}{"action": "round_started", "round": int64(7), "stage": int64(2)}},
+		{"Training round: 3 stage: 1", "stage_started", "round_event",
+			map[string]interface{}{"action": "stage_started", "round": int64(3), "stage": int64(1)}},
+		{"Finished training round: 3 stage: 1", "stage_finished", "round_event",
+			map[string]interface{}{"action": "stage_finished", "round": int64(3), "stage": int64(1)}},
+		{"Already finished round: 5. Next check in 300s.", "round_waiting", "round_event",
+			map[string]interface{}{"action": "round_waiting", "round": int64(5)}},
+		{"Completed round 9", "round_finished", "round_event",
+			map[string]interface{}{"action": "round_finished", "round": int64(9)}},
+		{"Submitted reward 1.5 for round 9 stage 2", "reward_submitted", "reward_event",
+			map[string]interface{}{"action": "reward_submitted", "reward": 1.5, "round": int64(9), "stage": int64(2)}},
+		{"CUDA out of memory. Tried to allocate 2.00 GiB", "out_of_memory", "error",
+			map[string]interface{}{"code": "OOM", "requested": "2.00 GiB"}},
+		{"Saving model checkpoint to /tmp/ckpt-100", "checkpoint_saved", "checkpoint",
+			map[string]interface{}{"action": "saved", "path": "/tmp/ckpt-100"}},
+		{"Peer QmAbc123 disconnected", "peer_dropped", "peer_event",
+			map[string]interface{}{"action": "drop", "peer_id": "QmAbc123"}},
+		{"Lost connection to peer QmDef456", "peer_lost", "peer_event",
+			map[string]interface{}{"action": "drop", "peer_id": "QmDef456"}},
+		{"{'loss': 0.0123, 'reward': 0.5, 'epoch': 2}", "training_metrics", "training_progress",
+			map[string]interface{}{"loss": 0.0123, "reward": 0.5, "epoch": 2.0}},
+	} {
+		event, rule := parseSwarmLogLine(swarmLine("INFO", "hivemind_exp.trainer", tc.msg), cfg, swarmRules)
+		if rule == nil || rule.Name != tc.rule {
+			t.Errorf("%q matched rule %v, want %s", tc.msg, rule, tc.rule)
+			continue
+		}
+		if event.EventType != tc.eventType || event.Level != "info" || event.NodeID != "node-1" {
+			t.Errorf("%q: event type %q level %q node %q", tc.msg, event.EventType, event.Level, event.NodeID)
+		}
+		if want := time.Date(2025, 1, 2, 3, 4, 5, 678e6, time.UTC); !event.Timestamp.Equal(want) {
+			t.Errorf("%q: timestamp %v, want %v", tc.msg, event.Timestamp, want)
+		}
+		for k, v := range tc.want {
+			if got := event.Details[k]; !reflect.DeepEqual(got, v) {
+				t.Errorf("%q: %s = %#v, want %#v", tc.msg, k, got, v)
+			}
+		}
+		if event.Details["logger"] != "hivemind_exp.trainer" || event.Details["message"] != tc.msg {
+			t.Errorf("%q: details lost the record: %v", tc.msg, event.Details)
+		}
+	}
+}
+
+func TestSwarmLevelEvents(t *testing.T) {
+	cfg := &config.Config{}
+	event, rule := parseSwarmLogLine(swarmLine("WARNING", "genrl", "GPU is warm"), cfg, swarmRules)
+	if rule != nil || event.EventType != "warning" || event.Level != "warning" {
+		t.Errorf("got %+v from rule %v, want a warning event", event, rule)
+	}
+
+	event, _ = parseSwarmLogLine(swarmLine("INFO", "hivemind", "Joining swarm with initial_peers = ['/ip4/1.2.3.4/tcp/1', '/ip4/5.6.7.8/tcp/2']"), cfg, swarmRules)
+	if event.EventType != "peer_event" || !reflect.DeepEqual(event.Details["peers"], []string{"/ip4/1.2.3.4/tcp/1", "/ip4/5.6.7.8/tcp/2"}) {
+		t.Errorf("peer join parsed as %+v", event)
+	}
+}
+
+func TestSwarmTracebackEvent(t *testing.T) {
+	line := swarmLine("ERROR", "trainer", "Step failed") +
+		"\nTraceback (most recent call last):\n  File \"train.py\", line 1\ntorch.OutOfMemoryError: CUDA out of memory"
+	event, rule := parseSwarmLogLine(line, &config.Config{}, swarmRules)
+	if event.EventType != "error" || event.Level != "error" {
+		t.Fatalf("parsed as %+v", event)
+	}
+	if event.Details["exception_type"] != "torch.OutOfMemoryError" || event.Details["message"] != "Step failed" {
+		t.Errorf("details = %v", event.Details)
+	}
+	// Known failures are tagged without changing the event type.
+	if rule == nil || rule.Name != "out_of_memory" || event.Details["code"] != "OOM" {
+		t.Errorf("rule %v, code %v", rule, event.Details["code"])
+	}
+}
+
+func TestConvertField(t *testing.T) {
+	for _, tc := range []struct {
+		value, kind string
+		want        interface{}
+	}{
+		{"42", fieldInt, int64(42)},
+		{"4.5", fieldFloat, 4.5},
+		{"True", fieldBool, true},
+		{"2.5s", fieldDuration, 2500.0},
+		{"450 ms", fieldDuration, 450.0},
+		{"abc", fieldInt, "abc"},
+		{"x", "", "x"},
+	} {
+		if got := convertField(tc.value, tc.kind); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("convertField(%q, %q) = %#v, want %#v", tc.value, tc.kind, got, tc.want)
+		}
+	}
+}
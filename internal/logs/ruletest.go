@@ -0,0 +1,169 @@
+package logs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gswarm-sidecar/internal/config"
+)
+
+// RunRuleTest parses samplePath with the configured multiline and extraction
+// rules and writes the resulting events to w, scrubbed as they would be sent,
+// without sending anything. Rules are selected as if the sample were the file
+// at asPath (the sample itself when empty). It returns an error if the sample
+// cannot be read or any configured rule or scrubbing setting is invalid.
+func RunRuleTest(cfg *config.Config, samplePath, asPath string, w io.Writer) error {
+	if asPath == "" {
+		asPath = samplePath
+	}
+	if abs, err := filepath.Abs(asPath); err == nil {
+		asPath = abs
+	}
+
+	userRules, errs := compileRules(cfg.LogMonitoring.Rules)
+	for _, err := range errs {
+		fmt.Fprintf(w, "invalid rule: %v\n", err)
+	}
+
+	// Use the settings of the log_files entry that covers the file, if any.
+	var entry config.LogFileConfig
+	for _, fileCfg := range cfg.LogMonitoring.LogFiles {
+		files, _ := expandLogPattern(fileCfg.Path)
+		if slices.Contains(files, asPath) {
+			entry = fileCfg
+			break
+		}
+	}
+	scrubber, scrubErrs := newScrubber(cfg.LogMonitoring.Scrubbing, cfg.NodeID)
+	for _, err := range scrubErrs {
+		fmt.Fprintf(w, "invalid scrubbing setting: %v\n", err)
+	}
+	errs = append(errs, scrubErrs...)
+	multiline, err := compileMultiline(multilineConfig(entry, cfg.LogMonitoring.Multiline))
+	if err != nil {
+		fmt.Fprintf(w, "invalid multiline rule: %v\n", err)
+		errs = append(errs, err)
+	}
+	filter, err := compileEventFilter(fileFilters(entry, cfg))
+	if err != nil {
+		fmt.Fprintf(w, "invalid filter: %v\n", err)
+		errs = append(errs, err)
+	}
+	parser, err := selectParser(entry.Parser, asPath, cfg)
+	if err != nil {
+		fmt.Fprintf(w, "invalid parser: %v\n", err)
+		errs = append(errs, err)
+	}
+	rules := rulesForFile(userRules, asPath, parser.Rules)
+	var pathFields map[string]interface{}
+	if parser.PathFields != nil {
+		pathFields = parser.PathFields(asPath)
+	}
+
+	f, err := os.Open(samplePath)
+	if err != nil {
+		return fmt.Errorf("failed to open sample: %w", err)
+	}
+	defer f.Close()
+
+	fmt.Fprintf(w, "Testing %s as %s with the %s parser: %d configured rule(s) apply, %d built-in\n",
+		samplePath, asPath, parser.Name, len(rules)-len(parser.Rules), len(parser.Rules))
+
+	var records, events, dropped, filtered int
+	counts := make(map[string]int)
+	scrubbed := make(map[string]int)
+	assembler := newMultilineAssembler(multiline)
+	report := func(record tailLine) {
+		records++
+		// Checkpoint.Offset carries the line number of the record's last line.
+		last := record.Checkpoint.Offset
+		first := last - int64(strings.Count(record.Text, "\n"))
+		where := fmt.Sprintf("line %d", first)
+		if first != last {
+			where = fmt.Sprintf("lines %d-%d", first, last)
+		}
+
+		event, rule := parser.Parse(record.Text, cfg, rules)
+		source := parser.Name + " parser"
+		if rule != nil {
+			source = fmt.Sprintf("rule %q", rule.Name)
+		}
+		if event == nil {
+			dropped++
+			if rule != nil {
+				fmt.Fprintf(w, "%s: dropped by %s\n", where, source)
+			} else {
+				fmt.Fprintf(w, "%s: skipped by %s\n", where, source)
+			}
+			return
+		}
+		addMissing(event.Details, pathFields)
+		for detector, n := range scrubber.Scrub(event) {
+			scrubbed[detector] += n
+		}
+		details, _ := json.Marshal(event.Details)
+		if ok, why := filter.Allow(event); !ok {
+			filtered++
+			fmt.Fprintf(w, "%s: %s -> %s %s, not sent: %s\n", where, source, event.EventType, details, why)
+			return
+		}
+		events++
+		counts[event.EventType]++
+		fmt.Fprintf(w, "%s: %s -> %s %s\n", where, source, event.EventType, details)
+	}
+
+	reader := bufio.NewReader(f)
+	var lineNum int64
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			lineNum++
+			text := strings.TrimRight(line, "\r\n")
+			for _, record := range assembler.Add(tailLine{Text: text, Checkpoint: fileCheckpoint{Offset: lineNum}}) {
+				report(record)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read sample: %w", err)
+		}
+	}
+	if record, ok := assembler.Flush(); ok {
+		report(record)
+	}
+
+	fmt.Fprintf(w, "\n%d line(s), %d record(s), %d event(s), %d dropped or skipped, %d filtered\n", lineNum, records, events, dropped, filtered)
+	types := make([]string, 0, len(counts))
+	for eventType := range counts {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	for _, eventType := range types {
+		fmt.Fprintf(w, "  %-20s %d\n", eventType, counts[eventType])
+	}
+	if len(scrubbed) > 0 {
+		fmt.Fprintf(w, "scrubbed values by detector:\n")
+		detectors := make([]string, 0, len(scrubbed))
+		for detector := range scrubbed {
+			detectors = append(detectors, detector)
+		}
+		sort.Strings(detectors)
+		for _, detector := range detectors {
+			fmt.Fprintf(w, "  %-20s %d\n", detector, scrubbed[detector])
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d invalid rule(s) or setting(s) in config", len(errs))
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package logs
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gswarm-sidecar/internal/config"
+)
+
+func TestCompileRules(t *testing.T) {
+	rules, errs := compileRules([]config.LogRuleConfig{
+		{Name: "step", Pattern: `step (?P<step>\d+) took (?P<took>\S+)`, EventType: "training_progress",
+			Fields: map[string]string{"step": "int", "took": "duration"}},
+		{Name: "noise", Pattern: `heartbeat`, Action: "drop"},
+		{Name: "no type", Pattern: `x`},
+		{Name: "no pattern", EventType: "x"},
+		{Name: "bad pattern", Pattern: `(`, EventType: "x"},
+		{Name: "bad action", Pattern: `x`, Action: "keep-ish"},
+		{Name: "bad field", Pattern: `(?P<a>x)`, EventType: "x", Fields: map[string]string{"b": "int"}},
+		{Name: "bad type", Pattern: `(?P<a>x)`, EventType: "x", Fields: map[string]string{"a": "date"}},
+		{Name: "bad glob", Pattern: `x`, EventType: "x", Files: []string{"["}},
+	})
+	if len(rules) != 2 || rules[0].Name != "step" || !rules[1].Drop {
+		t.Fatalf("compiled %d rules", len(rules))
+	}
+	if len(errs) != 7 {
+		t.Errorf("%d errors, want 7: %v", len(errs), errs)
+	}
+	for _, err := range errs {
+		if !strings.HasPrefix(err.Error(), "log rule ") {
+			t.Errorf("error %q does not name the rule", err)
+		}
+	}
+
+	details := map[string]interface{}{}
+	if rule := applyRules(rules, details, "step 12 took 1.5s"); rule != rules[0] {
+		t.Fatalf("matched %v", rule)
+	}
+	if want := map[string]interface{}{"step": int64(12), "took": 1500.0}; !reflect.DeepEqual(details, want) {
+		t.Errorf("details = %v, want %v", details, want)
+	}
+
+	details = map[string]interface{}{"message": "heartbeat"}
+	if rule := applyRules(rules, details, "heartbeat"); rule != rules[1] || len(details) != 1 {
+		t.Errorf("drop rule %v merged %v", rule, details)
+	}
+}
+
+func TestRuleFiles(t *testing.T) {
+	dir := t.TempDir()
+	rule := &extractionRule{Files: []string{"yarn*.log", filepath.Join(dir, "**", "output.log")}}
+	for path, want := range map[string]bool{
+		"/var/log/yarn-1.log":                              true,
+		"/var/log/swarm.log":                               false,
+		filepath.Join(dir, "wandb", "run-1", "output.log"): true,
+		filepath.Join(dir, "output.log"):                   true,
+		"/elsewhere/output.log":                            false,
+	} {
+		if got := rule.appliesTo(path); got != want {
+			t.Errorf("appliesTo(%s) = %v, want %v", path, got, want)
+		}
+	}
+
+	user := []*extractionRule{rule, {Name: "all"}}
+	if got := rulesForFile(user, "/var/log/swarm.log", swarmRules); len(got) != 1+len(swarmRules) || got[0].Name != "all" {
+		t.Errorf("rules for swarm.log start with %v", got[0])
+	}
+}
+
+func TestRunRuleTest(t *testing.T) {
+	dir := t.TempDir()
+	sample := filepath.Join(dir, "swarm.log")
+	writeFile(t, sample, strings.Join([]string{
+		swarmLine("INFO", "trainer", "step 3 took 250ms"),
+		swarmLine("INFO", "trainer", "heartbeat"),
+		swarmLine("INFO", "trainer", "Starting round: 4/10."),
+		swarmLine("ERROR", "trainer", "failed for alice@example.com"),
+		"Traceback (most recent call last):",
+		`  File "a.py", line 1`,
+		"ValueError: bad",
+	}, "\n")+"\n")
+
+	cfg := &config.Config{}
+	cfg.LogMonitoring.Rules = []config.LogRuleConfig{
+		{Name: "step", Pattern: `step (?P<step>\d+) took (?P<took>\S+)`, EventType: "training_progress",
+			Fields: map[string]string{"step": "int", "took": "duration"}},
+		{Name: "noise", Pattern: `heartbeat`, Action: "drop"},
+	}
+
+	var out strings.Builder
+	if err := RunRuleTest(cfg, sample, "", &out); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		`line 1: rule "step" -> training_progress`,
+		`"took":250`,
+		`line 2: dropped by rule "noise"`,
+		`line 3: rule "round_started" -> round_event`,
+		`lines 4-7: swarm parser -> error`,
+		`"exception_type":"ValueError"`,
+		"7 line(s), 4 record(s), 3 event(s), 1 dropped or skipped, 0 filtered",
+		"email                1",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "alice@example.com") {
+		t.Errorf("output is not scrubbed:\n%s", got)
+	}
+
+	cfg.LogMonitoring.Rules = append(cfg.LogMonitoring.Rules, config.LogRuleConfig{Name: "broken", Pattern: "("})
+	out.Reset()
+	if err := RunRuleTest(cfg, sample, "", &out); err == nil || !strings.Contains(out.String(), `invalid rule: log rule "broken"`) {
+		t.Errorf("invalid rule not reported: %v\n%s", err, out.String())
+	}
+
+	if err := RunRuleTest(cfg, filepath.Join(dir, "missing.log"), "", &out); err == nil {
+		t.Error("missing sample not reported")
+	}
+}
@@ -0,0 +1,271 @@
+package logs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gswarm-sidecar/internal/config"
+)
+
+// Scrubbing modes.
+const (
+	scrubModeRedact = "redact"
+	scrubModeHash   = "hash"
+)
+
+const redactedToken = "[REDACTED]"
+
+// scrubDetectors are the built-in PII detectors. Where a pattern has a
+// "value" group, only that part of a match is replaced, so the key of a
+// key=value pair stays readable.
+var scrubDetectors = map[string][]*regexp.Regexp{
+	"email": {
+		regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`),
+	},
+	"ipv4": {
+		regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`),
+	},
+	"ipv6": {
+		regexp.MustCompile(`(?i)\b(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}\b`),
+		regexp.MustCompile(`(?i)(?:\b[0-9a-f]{1,4}:){1,7}:(?:[0-9a-f]{1,4}(?::[0-9a-f]{1,4}){0,6}\b)?`),
+		regexp.MustCompile(`(?i)::[0-9a-f]{1,4}(?::[0-9a-f]{1,4}){0,6}\b`),
+	},
+	// 40 hex digits exactly, so 64-digit transaction and block hashes are kept.
+	"wallet": {
+		regexp.MustCompile(`\b0x[a-fA-F0-9]{40}\b`),
+	},
+	"env_secret": {
+		regexp.MustCompile(`(?i)\b[A-Z0-9_]*(?:API_KEY|SECRET|PASSWORD|PASSWD|TOKEN|JWT|PRIVATE_KEY|DATABASE_URL|DB_PASS|ACCESS_KEY)[A-Z0-9_]*=(?P<value>[^\s"',]+)`),
+	},
+	"jwt": {
+		regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	},
+	// A raw private key looks like any other 32-byte hash, so only labelled
+	// keys and PEM blocks are detected.
+	"private_key": {
+		regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+		regexp.MustCompile(`(?i)\b(?:private|priv|secret|signing)[_ -]?key\b["']?\s*[:=]\s*["']?(?P<value>(?:0x)?[a-fA-F0-9]{64})\b`),
+	},
+	// BIP-39 phrases: 12 to 24 lowercase words after a label, or making up a
+	// whole value.
+	"mnemonic": {
+		regexp.MustCompile(`(?i)\b(?:mnemonic|seed[_ ]?phrase|recovery[_ ]?phrase|secret[_ ]?phrase)\b["']?\s*[:=]?\s*["']?(?P<value>[a-z]{3,8}(?:\s+[a-z]{3,8}){11,23})\b`),
+		regexp.MustCompile(`^\s*[a-z]{3,8}(?: [a-z]{3,8}){11,23}\s*$`),
+	},
+	"device_id": {
+		regexp.MustCompile(`(?i)\b(?:serial|device[_-]?id|uuid|guid|hwid|cpuid|gpuid)[\s:=]+(?P<value>[a-zA-Z0-9\-]{6,})`),
+	},
+	// Off by default: it also matches transaction hashes and peer IDs.
+	"long_hex": {
+		regexp.MustCompile(`\b[a-fA-F0-9]{16,}\b`),
+	},
+}
+
+// defaultScrubDetectors are enabled when the policy does not list detectors.
+var defaultScrubDetectors = []string{"email", "ipv4", "ipv6", "wallet", "env_secret", "jwt", "private_key", "mnemonic", "device_id"}
+
+type scrubDetector struct {
+	name     string
+	patterns []*regexp.Regexp
+	hash     bool
+}
+
+// scrubber applies a compiled scrubbing policy. It is not modified after
+// construction, so it is safe for concurrent use. A nil scrubber scrubs
+// nothing.
+type scrubber struct {
+	detectors []*scrubDetector
+	allow     []*regexp.Regexp
+	allowKeys map[string]bool
+	salt      []byte
+}
+
+// newScrubber compiles a scrubbing policy. Invalid entries are skipped and
+// reported in the returned errors, so a mistake never turns scrubbing off.
+func newScrubber(cfg config.ScrubConfig, nodeID string) (*scrubber, []error) {
+	if cfg.Disabled {
+		return nil, nil
+	}
+	var errs []error
+	s := &scrubber{
+		allowKeys: make(map[string]bool, len(cfg.AllowFields)),
+		salt:      []byte(cfg.HashSalt),
+	}
+	if cfg.HashSalt == "" {
+		s.salt = []byte(nodeID)
+	}
+
+	mode := strings.ToLower(cfg.Mode)
+	if err := checkScrubMode(mode); err != nil {
+		errs = append(errs, fmt.Errorf("scrubbing: %w", err))
+		mode = scrubModeRedact
+	}
+	modeFor := func(name, override string) bool {
+		override = strings.ToLower(override)
+		if override == "" {
+			override = strings.ToLower(cfg.Modes[name])
+		}
+		if err := checkScrubMode(override); err != nil {
+			errs = append(errs, fmt.Errorf("scrubbing detector %q: %w", name, err))
+			override = ""
+		}
+		if override == "" {
+			override = mode
+		}
+		return override == scrubModeHash
+	}
+
+	names := cfg.Detectors
+	if len(names) == 0 {
+		names = defaultScrubDetectors
+	}
+	for _, name := range names {
+		patterns, ok := scrubDetectors[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("scrubbing: unknown detector %q", name))
+			continue
+		}
+		s.detectors = append(s.detectors, &scrubDetector{name: name, patterns: patterns, hash: modeFor(name, "")})
+	}
+	for i, rule := range cfg.Custom {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("custom_%d", i+1)
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil || rule.Pattern == "" {
+			errs = append(errs, fmt.Errorf("scrubbing detector %q: invalid pattern: %v", name, err))
+			continue
+		}
+		s.detectors = append(s.detectors, &scrubDetector{name: name, patterns: []*regexp.Regexp{pattern}, hash: modeFor(name, rule.Mode)})
+	}
+
+	for _, expr := range cfg.Allowlist {
+		// Allowlist entries must match the whole value they exempt.
+		re, err := regexp.Compile(`^(?:` + expr + `)$`)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("scrubbing allowlist %q: %w", expr, err))
+			continue
+		}
+		s.allow = append(s.allow, re)
+	}
+	for _, key := range cfg.AllowFields {
+		s.allowKeys[key] = true
+	}
+	return s, errs
+}
+
+func checkScrubMode(mode string) error {
+	switch mode {
+	case "", scrubModeRedact, scrubModeHash:
+		return nil
+	}
+	return fmt.Errorf("unknown mode %q (want %s or %s)", mode, scrubModeRedact, scrubModeHash)
+}
+
+// Scrub replaces PII in the event's details, recursively, and returns the
+// number of replacements made by each detector.
+func (s *scrubber) Scrub(event *MetricEvent) map[string]int {
+	if s == nil {
+		return nil
+	}
+	counts := make(map[string]int)
+	event.Details = s.scrubMap(event.Details, counts)
+	return counts
+}
+
+func (s *scrubber) scrubMap(m map[string]interface{}, counts map[string]int) map[string]interface{} {
+	for k, v := range m {
+		if s.allowKeys[k] {
+			continue
+		}
+		m[k] = s.scrubValue(v, counts)
+	}
+	return m
+}
+
+func (s *scrubber) scrubValue(v interface{}, counts map[string]int) interface{} {
+	switch val := v.(type) {
+	case string:
+		for _, d := range s.detectors {
+			var n int
+			val, n = s.apply(d, val)
+			if n > 0 {
+				counts[d.name] += n
+			}
+		}
+		return val
+	case map[string]interface{}:
+		return s.scrubMap(val, counts)
+	case []interface{}:
+		for i, e := range val {
+			val[i] = s.scrubValue(e, counts)
+		}
+		return val
+	case []string:
+		for i, e := range val {
+			val[i] = s.scrubValue(e, counts).(string)
+		}
+		return val
+	}
+	return v
+}
+
+// apply replaces the matches of one detector in text.
+func (s *scrubber) apply(d *scrubDetector, text string) (string, int) {
+	n := 0
+	for _, re := range d.patterns {
+		matches := re.FindAllStringSubmatchIndex(text, -1)
+		if matches == nil {
+			continue
+		}
+		group := re.SubexpIndex("value")
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if group > 0 && m[2*group] >= 0 {
+				start, end = m[2*group], m[2*group+1]
+			}
+			if start == end || s.allowed(text[start:end]) {
+				continue
+			}
+			b.WriteString(text[last:start])
+			b.WriteString(s.replacement(d, text[start:end]))
+			last = end
+			n++
+		}
+		if last > 0 {
+			b.WriteString(text[last:])
+			text = b.String()
+		}
+	}
+	return text, n
+}
+
+func (s *scrubber) allowed(value string) bool {
+	for _, re := range s.allow {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// replacement returns the token a matched value is replaced with. In hash
+// mode equal values map to equal tokens, e.g. "[ipv4:1a2b3c4d5e6f]", so they
+// can still be correlated without being revealed.
+func (s *scrubber) replacement(d *scrubDetector, value string) string {
+	if !d.hash {
+		return redactedToken
+	}
+	mac := hmac.New(sha256.New, s.salt)
+	mac.Write([]byte(d.name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return "[" + d.name + ":" + hex.EncodeToString(mac.Sum(nil)[:6]) + "]"
+}
@@ -0,0 +1,324 @@
+package logs
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gswarm-sidecar/internal/config"
+)
+
+// Keys of structured records mapped onto the event rather than kept as
+// details, in order of preference.
+var (
+	structuredTimeKeys    = []string{"time", "timestamp", "ts", "@timestamp", "asctime"}
+	structuredLevelKeys   = []string{"level", "levelname", "lvl", "severity"}
+	structuredMessageKeys = []string{"msg", "message", "event"}
+	structuredLoggerKeys  = []string{"logger", "logger_name", "name"}
+	// structuredTracebackKeys hold a formatted Python traceback, as written by
+	// python-json-logger and structlog.
+	structuredTracebackKeys = []string{"exc_info", "exception", "traceback", "stack_trace"}
+)
+
+// structuredTimeLayouts are tried in order for string timestamps.
+var structuredTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05,000",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+}
+
+// parseJSONLogLine parses records written as one JSON object per line.
+func parseJSONLogLine(line string, cfg *config.Config, rules []*extractionRule) (*MetricEvent, *extractionRule) {
+	fields, ok := parseJSONRecord(line)
+	if !ok {
+		return unstructuredEvent(line, cfg, rules)
+	}
+	return structuredEvent(fields, cfg, rules)
+}
+
+// parseLogfmtLogLine parses records written as logfmt key=value pairs.
+func parseLogfmtLogLine(line string, cfg *config.Config, rules []*extractionRule) (*MetricEvent, *extractionRule) {
+	fields, ok := parseLogfmt(line)
+	if !ok {
+		return unstructuredEvent(line, cfg, rules)
+	}
+	return structuredEvent(fields, cfg, rules)
+}
+
+// unstructuredEvent handles a record that is not valid JSON or logfmt.
+func unstructuredEvent(line string, cfg *config.Config, rules []*extractionRule) (*MetricEvent, *extractionRule) {
+	if strings.TrimSpace(line) == "" {
+		return nil, nil
+	}
+	if event, rule, ok := tracebackEvent(line, time.Now(), "", "", cfg, rules); ok {
+		return event, rule
+	}
+	return rawEvent(line, cfg, rules)
+}
+
+// detectStructured parses line as JSON or logfmt when it looks like a
+// structured log record, i.e. it parses and has a message or level key. It
+// is used to pick structured lines out of files in other formats.
+func detectStructured(line string) (map[string]interface{}, bool) {
+	line = strings.TrimSpace(line)
+	var fields map[string]interface{}
+	var ok bool
+	if strings.HasPrefix(line, "{") {
+		fields, ok = parseJSONRecord(line)
+	} else if strings.Contains(line, "=") {
+		fields, ok = parseLogfmt(line)
+	}
+	if !ok {
+		return nil, false
+	}
+	_, hasMsg := firstKey(fields, structuredMessageKeys)
+	_, hasLevel := firstKey(fields, structuredLevelKeys)
+	return fields, hasMsg || hasLevel
+}
+
+// parseJSONRecord decodes a JSON object. Numbers become int64 when they are
+// integral and float64 otherwise.
+func parseJSONRecord(line string) (map[string]interface{}, bool) {
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+	var record map[string]interface{}
+	if err := dec.Decode(&record); err != nil || record == nil {
+		return nil, false
+	}
+	if dec.More() {
+		return nil, false // trailing data after the object
+	}
+	for k, v := range record {
+		record[k] = jsonValue(v)
+	}
+	return record, true
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = jsonValue(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+	}
+	return v
+}
+
+// parseLogfmt parses key=value pairs separated by whitespace. Quoted values
+// are kept as strings; bare values are typed as int, float or bool when they
+// parse as one. ok is false unless the whole line consists of pairs.
+func parseLogfmt(line string) (map[string]interface{}, bool) {
+	fields := make(map[string]interface{})
+	i := 0
+	for {
+		for i < len(line) && isLogfmtSpace(line[i]) {
+			i++
+		}
+		if i >= len(line) {
+			break
+		}
+
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != '"' && !isLogfmtSpace(line[i]) {
+			i++
+		}
+		if i == start || i >= len(line) || line[i] != '=' {
+			return nil, false
+		}
+		key := line[start:i]
+		i++
+
+		if i < len(line) && line[i] == '"' {
+			end := i + 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, false // unterminated quote
+			}
+			value, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				value = line[i+1 : end]
+			}
+			fields[key] = value
+			i = end + 1
+			continue
+		}
+
+		start = i
+		for i < len(line) && !isLogfmtSpace(line[i]) {
+			i++
+		}
+		fields[key] = logfmtValue(line[start:i])
+	}
+	return fields, len(fields) > 0
+}
+
+func isLogfmtSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func logfmtValue(s string) interface{} {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f
+	}
+	if b, err := strconv.ParseBool(s); err == nil && (s == "true" || s == "false") {
+		return b
+	}
+	return s
+}
+
+// structuredEvent maps the timestamp, level, message and logger keys of a
+// parsed record onto the event and keeps every other key as a detail.
+func structuredEvent(fields map[string]interface{}, cfg *config.Config, rules []*extractionRule) (*MetricEvent, *extractionRule) {
+	ts := time.Now()
+	if key, ok := firstKey(fields, structuredTimeKeys); ok {
+		if parsed, ok := structuredTime(fields[key]); ok {
+			ts = parsed
+			delete(fields, key)
+		}
+	}
+	level := ""
+	if key, ok := firstKey(fields, structuredLevelKeys); ok {
+		if s, ok := fields[key].(string); ok && s != "" {
+			level = normalizeLevel(s)
+			delete(fields, key)
+		}
+	}
+	msg := popString(fields, structuredMessageKeys)
+	logger := popString(fields, structuredLoggerKeys)
+
+	details := fields
+	if logger != "" {
+		details["logger"] = logger
+	}
+	if msg != "" {
+		details["message"] = msg
+	}
+
+	for _, key := range structuredTracebackKeys {
+		text, ok := details[key].(string)
+		if !ok {
+			continue
+		}
+		if event, rule, ok := tracebackEvent(text, ts, logger, msg, cfg, rules); ok {
+			if event != nil {
+				delete(details, key)
+				addMissing(event.Details, details)
+			}
+			if level != "" {
+				withLevel(event, level)
+			}
+			return event, rule
+		}
+	}
+
+	eventType := level
+	if eventType == "" {
+		eventType = "info"
+	}
+	if event, rule := ruleEvent(rules, cfg, ts, details, msg); rule != nil {
+		return withLevel(event, eventType), rule
+	}
+	return &MetricEvent{
+		NodeID:    cfg.NodeID,
+		Timestamp: ts,
+		EventType: eventType,
+		Details:   details,
+		Level:     eventType,
+	}, nil
+}
+
+// firstKey returns the first of keys present in fields.
+func firstKey(fields map[string]interface{}, keys []string) (string, bool) {
+	for _, key := range keys {
+		if _, ok := fields[key]; ok {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// popString removes and returns the first of keys holding a string.
+func popString(fields map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		if s, ok := fields[key].(string); ok {
+			delete(fields, key)
+			return s
+		}
+	}
+	return ""
+}
+
+// structuredTime parses a string timestamp or a Unix time in seconds,
+// milliseconds, microseconds or nanoseconds.
+func structuredTime(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case string:
+		for _, layout := range structuredTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, true
+			}
+		}
+	case int64:
+		return unixTime(float64(v)), true
+	case float64:
+		return unixTime(v), true
+	}
+	return time.Time{}, false
+}
+
+func unixTime(v float64) time.Time {
+	switch {
+	case v > 1e17:
+		return time.Unix(0, int64(v))
+	case v > 1e14:
+		return time.UnixMicro(int64(v))
+	case v > 1e11:
+		return time.UnixMilli(int64(v))
+	default:
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*1e9))
+	}
+}
+
+// normalizeLevel maps level names onto the event types used by the other
+// parsers.
+func normalizeLevel(level string) string {
+	switch level = strings.ToLower(strings.TrimSpace(level)); level {
+	case "warn":
+		return "warning"
+	case "err":
+		return "error"
+	case "dbg":
+		return "debug"
+	}
+	return level
+}
+
+// isJSONLines reports whether a file name suggests JSON lines.
+func isJSONLines(base string) bool {
+	return strings.HasSuffix(base, ".jsonl") || strings.HasSuffix(base, ".ndjson")
+}
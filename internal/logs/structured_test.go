@@ -0,0 +1,147 @@
+package logs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gswarm-sidecar/internal/config"
+)
+
+func TestDetectStructured(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		ok   bool
+	}{
+		{`{"level":"info","msg":"ready"}`, true},
+		{`  {"message":"ready"}`, true},
+		{`level=warn msg="disk low"`, true},
+		{`{"step":1}`, false},      // JSON without a message or level
+		{`step=1 loss=0.5`, false}, // logfmt without a message or level
+		{`{"msg":"x"} trailing`, false},
+		{`loss = 0.5`, false}, // not key=value pairs
+		{`plain text`, false},
+	} {
+		if _, ok := detectStructured(tc.line); ok != tc.ok {
+			t.Errorf("detectStructured(%q) = %v, want %v", tc.line, ok, tc.ok)
+		}
+	}
+}
+
+func TestParseLogfmt(t *testing.T) {
+	fields, ok := parseLogfmt(`level=info msg="peer \"a\" joined" step=12 loss=0.25 done=true id="42" path=/tmp/x`)
+	if !ok {
+		t.Fatal("not parsed")
+	}
+	want := map[string]interface{}{
+		"level": "info",
+		"msg":   `peer "a" joined`,
+		"step":  int64(12),
+		"loss":  0.25,
+		"done":  true,
+		"id":    "42", // quoted values stay strings
+		"path":  "/tmp/x",
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %#v, want %#v", fields, want)
+	}
+
+	for _, line := range []string{``, `msg="unterminated`, `=value`, `key`, `a=1 b`} {
+		if fields, ok := parseLogfmt(line); ok {
+			t.Errorf("parseLogfmt(%q) = %v", line, fields)
+		}
+	}
+}
+
+func TestParseJSONRecordNumbers(t *testing.T) {
+	fields, ok := parseJSONRecord(`{"step":3,"loss":0.5,"nested":{"n":[1,2.5]}}`)
+	if !ok {
+		t.Fatal("not parsed")
+	}
+	want := map[string]interface{}{
+		"step":   int64(3),
+		"loss":   0.5,
+		"nested": map[string]interface{}{"n": []interface{}{int64(1), 2.5}},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %#v", fields)
+	}
+	for _, line := range []string{`[1,2]`, `null`, `{"a":1}{"b":2}`, `{"a":`} {
+		if _, ok := parseJSONRecord(line); ok {
+			t.Errorf("parseJSONRecord(%q) succeeded", line)
+		}
+	}
+}
+
+func TestStructuredEventKeys(t *testing.T) {
+	cfg := &config.Config{NodeID: "node-1"}
+	want := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name   string
+		fields map[string]interface{}
+	}{
+		{"rfc3339", map[string]interface{}{"time": "2025-06-01T12:00:00Z"}},
+		{"asctime", map[string]interface{}{"asctime": "2025-06-01 12:00:00,000"}},
+		{"unix seconds", map[string]interface{}{"ts": int64(want.Unix())}},
+		{"unix float", map[string]interface{}{"ts": float64(want.Unix())}},
+		{"unix millis", map[string]interface{}{"timestamp": want.UnixMilli()}},
+		{"unix micros", map[string]interface{}{"timestamp": want.UnixMicro()}},
+	} {
+		tc.fields["levelname"] = "WARN"
+		tc.fields["message"] = "disk low"
+		tc.fields["name"] = "hivemind.dht"
+		tc.fields["disk"] = "/dev/sda"
+
+		event, rule := structuredEvent(tc.fields, cfg, nil)
+		if rule != nil || event == nil {
+			t.Fatalf("%s: got %+v from %v", tc.name, event, rule)
+		}
+		if !event.Timestamp.Equal(want) {
+			t.Errorf("%s: timestamp %v, want %v", tc.name, event.Timestamp, want)
+		}
+		if event.EventType != "warning" || event.Level != "warning" || event.NodeID != "node-1" {
+			t.Errorf("%s: event type %q level %q", tc.name, event.EventType, event.Level)
+		}
+		wantDetails := map[string]interface{}{"message": "disk low", "logger": "hivemind.dht", "disk": "/dev/sda"}
+		if !reflect.DeepEqual(event.Details, wantDetails) {
+			t.Errorf("%s: details = %v", tc.name, event.Details)
+		}
+	}
+
+	// An unparsable timestamp is kept as a detail.
+	event, _ := structuredEvent(map[string]interface{}{"time": "yesterday", "msg": "x"}, cfg, nil)
+	if event.Details["time"] != "yesterday" || event.EventType != "info" {
+		t.Errorf("event = %+v", event)
+	}
+}
+
+func TestStructuredEventTraceback(t *testing.T) {
+	fields, _ := parseJSONRecord(`{"time":"2025-06-01T12:00:00Z","level":"ERROR","msg":"step failed","step":7,` +
+		`"exc_info":"Traceback (most recent call last):\n  File \"a.py\", line 1\nValueError: bad value"}`)
+	event, _ := structuredEvent(fields, &config.Config{}, nil)
+	if event == nil || event.EventType != "error" || event.Level != "error" {
+		t.Fatalf("parsed as %+v", event)
+	}
+	if event.Details["exception_type"] != "ValueError" || event.Details["message"] != "step failed" || event.Details["step"] != int64(7) {
+		t.Errorf("details = %v", event.Details)
+	}
+	if _, ok := event.Details["exc_info"]; ok {
+		t.Error("exc_info kept alongside the parsed traceback")
+	}
+}
+
+func TestNormalizeLevel(t *testing.T) {
+	for in, want := range map[string]string{"WARN": "warning", " Err ": "error", "dbg": "debug", "INFO": "info", "critical": "critical"} {
+		if got := normalizeLevel(in); got != want {
+			t.Errorf("normalizeLevel(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestIsJSONLines(t *testing.T) {
+	for base, want := range map[string]bool{"events.jsonl": true, "out.ndjson": true, "config.json": false, "run.log": false} {
+		if got := isJSONLines(base); got != want {
+			t.Errorf("isJSONLines(%q) = %v", base, got)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package logs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+const tailPollInterval = 250 * time.Millisecond
+
+// tailLine is a complete line read from a file. Checkpoint points just past
+// the line, i.e. where reading resumes once the line has been delivered.
+type tailLine struct {
+	Text       string
+	Checkpoint fileCheckpoint
+}
+
+// tailer follows a single file by path, starting at a byte offset. It keeps
+// reading the open file until EOF when the path is rotated to a new inode,
+// and restarts from the beginning when the file is truncated in place.
+type tailer struct {
+	path   string
+	file   *os.File
+	reader *bufio.Reader
+	offset int64  // offset just past the last complete line
+	buf    []byte // partial line read without its newline yet
+	ident  fileCheckpoint
+}
+
+func openTailer(path string, offset int64) (*tailer, error) {
+	t := &tailer{path: path}
+	if err := t.open(offset); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *tailer) open(offset int64) error {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to seek %s: %w", t.path, err)
+	}
+	if t.file != nil {
+		_ = t.file.Close()
+	}
+	t.file = f
+	t.reader = bufio.NewReader(f)
+	t.offset = offset
+	t.buf = t.buf[:0]
+	t.ident = fileCheckpoint{}
+	t.refreshIdentity()
+	return nil
+}
+
+func (t *tailer) Close() {
+	if t.file != nil {
+		_ = t.file.Close()
+	}
+}
+
+// refreshIdentity records the device, inode and head-of-file fingerprint of
+// the open file. The fingerprint is recomputed until the file has grown past
+// fingerprintSize so that short files are still recognised later.
+func (t *tailer) refreshIdentity() {
+	if t.ident.FingerprintSize >= fingerprintSize && t.ident.Fingerprint != "" {
+		return
+	}
+	fi, err := t.file.Stat()
+	if err != nil {
+		return
+	}
+	fp, fpSize, err := fingerprint(t.file, fingerprintSize)
+	if err != nil {
+		return
+	}
+	t.ident.Device, t.ident.Inode = fileIdentity(fi)
+	t.ident.Fingerprint, t.ident.FingerprintSize = fp, fpSize
+}
+
+// Run sends lines to out until ctx is cancelled.
+func (t *tailer) Run(ctx context.Context, out chan<- tailLine) {
+	for {
+		line, err := t.reader.ReadBytes('\n')
+		t.buf = append(t.buf, line...)
+		if err == nil {
+			t.offset += int64(len(t.buf))
+			text := string(bytes.TrimRight(t.buf, "\r\n"))
+			t.buf = t.buf[:0]
+			if t.ident.FingerprintSize < fingerprintSize {
+				t.refreshIdentity()
+			}
+			cp := t.ident
+			cp.Offset = t.offset
+			select {
+			case out <- tailLine{Text: text, Checkpoint: cp}:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err != io.EOF {
+			log.Printf("[ERROR] Failed to read %s: %v", t.path, err)
+		}
+
+		// At EOF: wait for more data, then check whether the path now points
+		// at a different or truncated file.
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(tailPollInterval):
+		}
+		t.checkRotation()
+	}
+}
+
+func (t *tailer) checkRotation() {
+	pathInfo, err := os.Stat(t.path)
+	if err != nil {
+		// Removed or mid-rotation; keep the old handle until it reappears.
+		return
+	}
+	openInfo, err := t.file.Stat()
+	if err != nil {
+		return
+	}
+
+	read := t.offset + int64(len(t.buf))
+	switch {
+	case !os.SameFile(pathInfo, openInfo) && openInfo.Size() > read:
+		// Rotated after more was written; finish the old file first.
+	case !os.SameFile(pathInfo, openInfo):
+		// Everything up to EOF of the old file has been read; switch over.
+		log.Printf("[INFO] Log file rotated, reopening: %s", t.path)
+		if err := t.open(0); err != nil {
+			log.Printf("[ERROR] Failed to reopen rotated file %s: %v", t.path, err)
+		}
+	case openInfo.Size() < read:
+		// Truncated in place, e.g. by copytruncate.
+		log.Printf("[INFO] Log file truncated, restarting from the beginning: %s", t.path)
+		if err := t.open(0); err != nil {
+			log.Printf("[ERROR] Failed to reopen truncated file %s: %v", t.path, err)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package logs
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gswarm-sidecar/internal/config"
+)
+
+const maxDedupEntries = 1024 // distinct messages tracked per file
+
+// Reasons an event is not sent on its own, used as metric labels.
+const (
+	dropDeduplicated = "deduplicated"
+	dropRateLimited  = "rate_limited"
+	dropSampled      = "sampled"
+	dropFiltered     = "filtered"
+	dropBacklog      = "backlog" // oldest unsent events, once sending keeps failing
+)
+
+// logThrottle sits between parsing and batching for one file. Debug-level
+// events are sampled, identical messages within the dedup window are collapsed and
+// the remaining events are rate limited. A nil throttle passes everything.
+type logThrottle struct {
+	nodeID     string
+	window     time.Duration
+	rate       float64
+	burst      float64
+	sampleRate float64
+	onDrop     func(reason string)
+
+	repeats map[string]*repeatEntry
+	order   []string // repeats keys by window start
+
+	tokens     float64
+	lastRefill time.Time
+	limited    int       // events dropped since the last rate_limited summary
+	limitedAt  time.Time // first of them
+
+	sampleAcc float64
+}
+
+// repeatEntry tracks one message within its dedup window. The first
+// occurrence is sent at once; later ones are counted and sent as a single
+// event when the window closes.
+type repeatEntry struct {
+	start     time.Time
+	count     int
+	firstSeen time.Time
+	last      MetricEvent
+}
+
+// newLogThrottle returns the throttle for cfg, or nil when it would pass
+// every event.
+func newLogThrottle(cfg config.ThrottleConfig, nodeID string) (*logThrottle, error) {
+	switch {
+	case cfg.DedupWindow < 0:
+		return nil, fmt.Errorf("dedup_window must not be negative, got %d", cfg.DedupWindow)
+	case cfg.RateLimit < 0:
+		return nil, fmt.Errorf("rate_limit must not be negative, got %g", cfg.RateLimit)
+	case cfg.Burst < 0:
+		return nil, fmt.Errorf("burst must not be negative, got %d", cfg.Burst)
+	case cfg.DebugSampleRate < 0 || cfg.DebugSampleRate > 1:
+		return nil, fmt.Errorf("debug_sample_rate must be between 0 and 1, got %g", cfg.DebugSampleRate)
+	}
+
+	t := &logThrottle{
+		nodeID:     nodeID,
+		window:     time.Duration(cfg.DedupWindow) * time.Second,
+		rate:       cfg.RateLimit,
+		burst:      float64(cfg.Burst),
+		sampleRate: cfg.DebugSampleRate,
+		repeats:    make(map[string]*repeatEntry),
+	}
+	if t.burst == 0 {
+		t.burst = math.Max(math.Ceil(t.rate*10), 1)
+	}
+	t.tokens = t.burst
+	if t.sampleRate == 0 {
+		t.sampleRate = 1
+	}
+	if t.window == 0 && t.rate == 0 && t.sampleRate == 1 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// Active reports whether the throttle needs Tick to be called.
+func (t *logThrottle) Active() bool {
+	return t != nil && (t.window > 0 || t.rate > 0)
+}
+
+// Process returns the events to batch for a parsed event.
+func (t *logThrottle) Process(event MetricEvent, now time.Time) []MetricEvent {
+	if t == nil {
+		return []MetricEvent{event}
+	}
+	if t.sampleRate < 1 && eventLevel(&event) == "debug" {
+		t.sampleAcc += t.sampleRate
+		if t.sampleAcc < 1 {
+			t.drop(dropSampled)
+			return nil
+		}
+		t.sampleAcc--
+		if event.Details == nil {
+			event.Details = make(map[string]interface{})
+		}
+		event.Details["sample_rate"] = t.sampleRate
+	}
+
+	out := t.expire(now)
+	if t.window > 0 {
+		if key, ok := repeatKey(event); ok {
+			if entry, ok := t.repeats[key]; ok {
+				if entry.count == 0 {
+					entry.firstSeen = event.Timestamp
+				}
+				entry.count++
+				entry.last = event
+				t.drop(dropDeduplicated)
+				return out
+			}
+			if len(t.repeats) < maxDedupEntries {
+				t.repeats[key] = &repeatEntry{start: now}
+				t.order = append(t.order, key)
+			}
+		}
+	}
+	if t.allow(now) {
+		out = append(out, event)
+	}
+	return out
+}
+
+// Tick sends the repeats of windows that have closed and the summary of
+// rate-limited events. It should be called about once a second.
+func (t *logThrottle) Tick(now time.Time) []MetricEvent {
+	if t == nil {
+		return nil
+	}
+	out := t.expire(now)
+	// Summaries are sent once the limit eases, or every minute while it holds.
+	if t.limited > 0 && (t.refill(now) >= 1 || now.Sub(t.limitedAt) >= time.Minute) {
+		out = append(out, t.limitedSummary(now))
+	}
+	return out
+}
+
+// Flush returns every pending repeat and summary, regardless of windows and
+// rate, e.g. before the file stops being tailed.
+func (t *logThrottle) Flush(now time.Time) []MetricEvent {
+	if t == nil {
+		return nil
+	}
+	var out []MetricEvent
+	for _, key := range t.order {
+		if entry := t.repeats[key]; entry.count > 0 {
+			out = append(out, repeatEvent(entry))
+		}
+	}
+	t.repeats = make(map[string]*repeatEntry)
+	t.order = nil
+	if t.limited > 0 {
+		out = append(out, t.limitedSummary(now))
+	}
+	return out
+}
+
+// expire closes the dedup windows that have ended. Their repeats bypass the
+// rate limit, as each one stands for several already counted events.
+func (t *logThrottle) expire(now time.Time) []MetricEvent {
+	var out []MetricEvent
+	i := 0
+	for ; i < len(t.order); i++ {
+		entry := t.repeats[t.order[i]]
+		if now.Sub(entry.start) < t.window {
+			break
+		}
+		if entry.count > 0 {
+			out = append(out, repeatEvent(entry))
+		}
+		delete(t.repeats, t.order[i])
+	}
+	t.order = t.order[i:]
+	return out
+}
+
+// allow takes a token from the bucket, counting the event as dropped when
+// there is none.
+func (t *logThrottle) allow(now time.Time) bool {
+	if t.rate <= 0 {
+		return true
+	}
+	if t.refill(now) >= 1 {
+		t.tokens--
+		return true
+	}
+	if t.limited == 0 {
+		t.limitedAt = now
+	}
+	t.limited++
+	t.drop(dropRateLimited)
+	return false
+}
+
+// refill adds the tokens earned since the last refill and returns the
+// tokens available.
+func (t *logThrottle) refill(now time.Time) float64 {
+	if !t.lastRefill.IsZero() {
+		t.tokens = math.Min(t.burst, t.tokens+now.Sub(t.lastRefill).Seconds()*t.rate)
+	}
+	t.lastRefill = now
+	return t.tokens
+}
+
+func (t *logThrottle) drop(reason string) {
+	if t.onDrop != nil {
+		t.onDrop(reason)
+	}
+}
+
+// limitedSummary reports the events dropped by the rate limit since the last
+// summary.
+func (t *logThrottle) limitedSummary(now time.Time) MetricEvent {
+	event := MetricEvent{
+		NodeID:    t.nodeID,
+		Timestamp: now,
+		EventType: "rate_limited",
+		Details: map[string]interface{}{
+			"dropped_events": t.limited,
+			"since":          t.limitedAt.UTC().Format(time.RFC3339),
+			"rate_limit":     t.rate,
+		},
+	}
+	t.limited = 0
+	return event
+}
+
+// repeatKey identifies identical messages. Events without a message are
+// never collapsed.
+func repeatKey(event MetricEvent) (string, bool) {
+	var key string
+	found := false
+	for _, field := range []string{"logger", "message", "raw_line", "stack_trace"} {
+		s, _ := event.Details[field].(string)
+		if s != "" && field != "logger" {
+			found = true
+		}
+		key += "\x00" + s
+	}
+	return event.EventType + key, found
+}
+
+// repeatEvent is the last repeat of a message, carrying how many repeats it
+// stands for.
+func repeatEvent(entry *repeatEntry) MetricEvent {
+	event := entry.last
+	details := make(map[string]interface{}, len(event.Details)+2)
+	for k, v := range event.Details {
+		details[k] = v
+	}
+	details["repeat_count"] = entry.count
+	details["first_seen"] = entry.firstSeen.UTC().Format(time.RFC3339)
+	event.Details = details
+	return event
+}
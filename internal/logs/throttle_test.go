@@ -0,0 +1,176 @@
+package logs
+
+import (
+	"testing"
+	"time"
+
+	"gswarm-sidecar/internal/config"
+)
+
+var throttleStart = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func throttleEvent(level, msg string, at time.Time) MetricEvent {
+	return MetricEvent{
+		Timestamp: at,
+		EventType: level,
+		Level:     level,
+		Details:   map[string]interface{}{"logger": "trainer", "message": msg},
+	}
+}
+
+func newTestThrottle(t *testing.T, cfg config.ThrottleConfig) (*logThrottle, map[string]int) {
+	t.Helper()
+	th, err := newLogThrottle(cfg, "node-1")
+	if err != nil || th == nil {
+		t.Fatalf("newLogThrottle(%+v) = %v, %v", cfg, th, err)
+	}
+	drops := map[string]int{}
+	th.onDrop = func(reason string) { drops[reason]++ }
+	return th, drops
+}
+
+func TestThrottleDedupWindow(t *testing.T) {
+	th, drops := newTestThrottle(t, config.ThrottleConfig{DedupWindow: 10})
+
+	var sent []MetricEvent
+	for i := 0; i < 4; i++ {
+		at := throttleStart.Add(time.Duration(i) * time.Second)
+		sent = append(sent, th.Process(throttleEvent("warning", "GPU is warm", at), at)...)
+	}
+	sent = append(sent, th.Process(throttleEvent("warning", "other", throttleStart), throttleStart)...)
+	if len(sent) != 2 || sent[0].Details["repeat_count"] != nil {
+		t.Fatalf("sent %v, want the first occurrence of each message", sent)
+	}
+	if drops[dropDeduplicated] != 3 {
+		t.Errorf("drops = %v, want 3 deduplicated", drops)
+	}
+
+	if out := th.Tick(throttleStart.Add(9 * time.Second)); len(out) != 0 {
+		t.Fatalf("window closed early: %v", out)
+	}
+	out := th.Tick(throttleStart.Add(10 * time.Second))
+	if len(out) != 1 {
+		t.Fatalf("window close sent %v, want one repeat event", out)
+	}
+	repeat := out[0]
+	if repeat.Details["repeat_count"] != 3 || repeat.Details["first_seen"] != "2025-01-02T03:04:06Z" ||
+		!repeat.Timestamp.Equal(throttleStart.Add(3*time.Second)) {
+		t.Errorf("repeat event = %+v", repeat)
+	}
+
+	// A new window starts with the next occurrence.
+	at := throttleStart.Add(11 * time.Second)
+	if out := th.Process(throttleEvent("warning", "GPU is warm", at), at); len(out) != 1 {
+		t.Errorf("next window sent %v", out)
+	}
+}
+
+func TestThrottleDedupFlush(t *testing.T) {
+	th, _ := newTestThrottle(t, config.ThrottleConfig{DedupWindow: 60})
+	th.Process(throttleEvent("info", "a", throttleStart), throttleStart)
+	th.Process(throttleEvent("info", "a", throttleStart), throttleStart)
+	th.Process(throttleEvent("info", "b", throttleStart), throttleStart)
+	out := th.Flush(throttleStart)
+	if len(out) != 1 || out[0].Details["message"] != "a" || out[0].Details["repeat_count"] != 1 {
+		t.Errorf("flushed %v", out)
+	}
+	if out := th.Flush(throttleStart); len(out) != 0 {
+		t.Errorf("second flush sent %v", out)
+	}
+}
+
+func TestThrottleRateLimit(t *testing.T) {
+	th, drops := newTestThrottle(t, config.ThrottleConfig{RateLimit: 1, Burst: 2})
+
+	sent := 0
+	for i := 0; i < 5; i++ {
+		sent += len(th.Process(throttleEvent("info", "step", throttleStart), throttleStart))
+	}
+	if sent != 2 || drops[dropRateLimited] != 3 {
+		t.Fatalf("sent %d, drops %v; want the burst of 2 and 3 rate limited", sent, drops)
+	}
+
+	// No token yet: the summary waits.
+	if out := th.Tick(throttleStart.Add(500 * time.Millisecond)); len(out) != 0 {
+		t.Fatalf("summary sent while limited: %v", out)
+	}
+	out := th.Tick(throttleStart.Add(time.Second))
+	if len(out) != 1 {
+		t.Fatalf("tick sent %v, want the rate_limited summary", out)
+	}
+	summary := out[0]
+	if summary.EventType != "rate_limited" || summary.NodeID != "node-1" || summary.Details["dropped_events"] != 3 ||
+		summary.Details["since"] != "2025-01-02T03:04:05Z" || summary.Details["rate_limit"] != 1.0 {
+		t.Errorf("summary = %+v", summary)
+	}
+
+	// The token earned is still available after the summary.
+	at := throttleStart.Add(time.Second)
+	if out := th.Process(throttleEvent("info", "step", at), at); len(out) != 1 {
+		t.Errorf("event after refill dropped")
+	}
+}
+
+func TestThrottleSummaryWhileLimited(t *testing.T) {
+	th, _ := newTestThrottle(t, config.ThrottleConfig{RateLimit: 0.001, Burst: 1})
+	th.Process(throttleEvent("info", "a", throttleStart), throttleStart)
+	th.Process(throttleEvent("info", "b", throttleStart), throttleStart)
+	if out := th.Tick(throttleStart.Add(59 * time.Second)); len(out) != 0 {
+		t.Fatalf("summary before a minute: %v", out)
+	}
+	if out := th.Tick(throttleStart.Add(time.Minute)); len(out) != 1 || out[0].Details["dropped_events"] != 1 {
+		t.Errorf("tick after a minute sent %v", out)
+	}
+}
+
+func TestThrottleSamplesDebug(t *testing.T) {
+	th, drops := newTestThrottle(t, config.ThrottleConfig{DebugSampleRate: 0.25})
+
+	var sent []MetricEvent
+	for i := 0; i < 8; i++ {
+		sent = append(sent, th.Process(throttleEvent("debug", "tick", throttleStart), throttleStart)...)
+	}
+	if len(sent) != 2 || drops[dropSampled] != 6 {
+		t.Fatalf("sent %d, drops %v; want 1 in 4", len(sent), drops)
+	}
+	if sent[0].Details["sample_rate"] != 0.25 {
+		t.Errorf("details = %v", sent[0].Details)
+	}
+	if out := th.Process(throttleEvent("info", "kept", throttleStart), throttleStart); len(out) != 1 {
+		t.Error("info event sampled")
+	}
+
+	// Events without details are sampled too.
+	th.sampleAcc = 0.75
+	out := th.Process(MetricEvent{EventType: "debug", Level: "debug"}, throttleStart)
+	if len(out) != 1 || out[0].Details["sample_rate"] != 0.25 {
+		t.Errorf("sent %v", out)
+	}
+}
+
+func TestNewLogThrottle(t *testing.T) {
+	for _, cfg := range []config.ThrottleConfig{
+		{DedupWindow: -1},
+		{RateLimit: -1},
+		{Burst: -1},
+		{DebugSampleRate: 1.5},
+	} {
+		if _, err := newLogThrottle(cfg, ""); err == nil {
+			t.Errorf("%+v accepted", cfg)
+		}
+	}
+
+	th, err := newLogThrottle(config.ThrottleConfig{DebugSampleRate: 1}, "")
+	if th != nil || err != nil {
+		t.Fatalf("pass-through config gave %v, %v", th, err)
+	}
+	event := throttleEvent("debug", "x", throttleStart)
+	if out := th.Process(event, throttleStart); len(out) != 1 || th.Active() || th.Tick(throttleStart) != nil {
+		t.Error("nil throttle is not a pass-through")
+	}
+
+	th, _ = newLogThrottle(config.ThrottleConfig{RateLimit: 2.5}, "")
+	if th.burst != 25 || !th.Active() {
+		t.Errorf("burst = %g, want 10 seconds' worth", th.burst)
+	}
+}
@@ -2,27 +2,36 @@ package monitor
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"sync"
 
 	"gswarm-sidecar/internal/blockchain"
 	"gswarm-sidecar/internal/config"
 	"gswarm-sidecar/internal/dht"
+	"gswarm-sidecar/internal/health"
 	"gswarm-sidecar/internal/logs"
 	"gswarm-sidecar/internal/processor"
 	"gswarm-sidecar/internal/system"
 	"gswarm-sidecar/internal/transmitter"
 )
 
-const numMonitors = 4
-
 type Monitor struct {
 	cfg         *config.Config
 	logs        *logs.Monitor
-	dht         *dht.Monitor
-	blockchain  *blockchain.Monitor
-	system      *system.Monitor
 	processor   *processor.Processor
 	transmitter *transmitter.Transmitter
+	health      *health.Server
+
+	// The optional monitors, nil while disabled. dht, blockchain and system
+	// are restarted when their config section changes on reload; logs
+	// applies changes itself.
+	logsRun    *component
+	dht        *component
+	blockchain *component
+	system     *component
+
+	reloadMu sync.Mutex // serializes Reload
 
 	ctx    context.Context
 	cancel context.CancelFunc
@@ -45,38 +54,110 @@ func (m *Monitor) Start() error {
 	m.processor = processor.New(m.transmitter, m.cfg.NodeID, m.cfg)
 
 	// Initialize monitoring components
-	m.logs = logs.New(m.cfg, m.processor)
-	m.dht = dht.New(m.cfg, m.processor)
-	m.blockchain = blockchain.New(m.cfg, m.processor)
-	m.system = system.New(m.cfg, m.processor)
+	m.health = health.NewServer(m.cfg.System.HealthPort, m.cfg.NodeID, m.processor.Status())
+	m.health.Handle("/metrics", m.processor.Metrics().Handler())
 
 	// Start monitoring components
-	m.wg.Add(numMonitors)
+	m.startService("transmitter", m.transmitter.Start)
+	m.startService("processor", m.processor.Start)
+	m.startService("health server", m.health.Start)
+	m.startService("config watcher", m.watchConfig)
 
-	go func() {
-		defer m.wg.Done()
-		m.logs.Start(m.ctx)
-	}()
+	// Start the optional monitors enabled in the config
+	m.applyEnabled(&config.Config{}, m.cfg)
 
-	go func() {
-		defer m.wg.Done()
-		m.dht.Start(m.ctx)
-	}()
+	return nil
+}
 
-	go func() {
-		defer m.wg.Done()
-		m.blockchain.Start(m.ctx)
-	}()
+// applyEnabled starts the monitors enabled in cfg, stops those disabled and
+// restarts those whose settings differ from old.
+func (m *Monitor) applyEnabled(old, cfg *config.Config) {
+	logsOn := config.Enabled(cfg.LogMonitoring.Enabled)
+	switch {
+	case m.logsRun != nil && logsOn:
+		m.logs.Reload(cfg)
+	case m.logsRun == nil && logsOn:
+		m.logs = logs.New(cfg, m.processor)
+	}
+	m.logsRun = m.toggle(processor.ComponentLogs, m.logsRun, logsOn, false,
+		func() starter { return m.logs })
+
+	m.dht = m.toggle(processor.ComponentDHT, m.dht, config.Enabled(cfg.DHT.Enabled),
+		!equal(old.DHT, cfg.DHT), func() starter { return dht.New(cfg, m.processor) })
+	m.blockchain = m.toggle(processor.ComponentBlockchain, m.blockchain, config.Enabled(cfg.Blockchain.Enabled),
+		!equal(old.Blockchain, cfg.Blockchain), func() starter { return blockchain.New(cfg, m.processor) })
+	m.system = m.toggle(processor.ComponentSystem, m.system, config.Enabled(cfg.System.Enabled),
+		!equal(old.System, cfg.System), func() starter { return system.New(cfg, m.processor) })
+}
 
-	go func() {
-		defer m.wg.Done()
-		m.system.Start(m.ctx)
-	}()
+// starter is implemented by the optional monitors.
+type starter interface {
+	Start(ctx context.Context)
+}
 
+// toggle starts, stops or restarts the named component c and returns the
+// one now running, or nil. newMonitor is only called when a monitor is
+// started.
+func (m *Monitor) toggle(name string, c *component, enabled, changed bool, newMonitor func() starter) *component {
+	switch {
+	case c != nil && !enabled:
+		log.Printf("[INFO] %s monitor disabled, stopping it", name)
+		c.stop()
+		m.processor.Status().Unregister(name)
+		return nil
+	case c != nil && changed:
+		log.Printf("[INFO] %s settings changed, restarting %s monitor", name, name)
+		c.stop()
+	case c != nil:
+		return c
+	case !enabled:
+		return nil
+	}
+	return m.startComponent(name, newMonitor().Start)
+}
+
+// Reload loads the config file again and applies it to the running monitors.
+// When the new config cannot be loaded or is invalid, the current one stays
+// in effect and the error is returned.
+//
+// Monitors are started and stopped as their enabled switches change. Node ID,
+// storage, health port and sinks only take effect on restart; any changes to
+// them are reported and ignored.
+func (m *Monitor) Reload() error {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if errs := logs.CheckConfig(cfg); len(errs) > 0 {
+		for _, err := range errs {
+			log.Printf("[ERROR] Reloaded config: %v", err)
+		}
+		return fmt.Errorf("%d invalid setting(s) in config", len(errs))
+	}
+
+	old := m.cfg
+	keepRestartOnly(old, cfg)
+	m.cfg = cfg
+	m.processor.SetConfig(cfg)
+	m.applyEnabled(old, cfg)
+	log.Printf("[INFO] Config reloaded from %s", config.Path())
 	return nil
 }
 
 func (m *Monitor) Stop() {
+	// Stop the monitors before the sinks, so their final batches are still
+	// delivered or queued.
+	m.reloadMu.Lock()
+	for _, c := range []*component{m.logsRun, m.dht, m.blockchain, m.system} {
+		if c != nil {
+			c.stop()
+		}
+	}
 	m.cancel()
+	m.reloadMu.Unlock()
 	m.wg.Wait()
+	m.transmitter.Close()
 }
@@ -0,0 +1,130 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"gswarm-sidecar/internal/config"
+)
+
+const configPollInterval = 2 * time.Second
+
+// watchConfig reloads the config whenever the contents of the config file,
+// or of a secret file named by a _FILE variable, change, e.g. when Kubernetes
+// rotates a mounted secret. Editors that write in several steps may briefly
+// leave an invalid file; that reload fails, the old config stays and the next
+// change retries.
+func (m *Monitor) watchConfig(ctx context.Context) {
+	// The environment cannot change, so neither can the secret files.
+	m.reloadMu.Lock()
+	paths := append([]string{config.Path()}, m.cfg.SecretFiles()...)
+	m.reloadMu.Unlock()
+	last := readAll(paths)
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			data := readAll(paths)
+			if data == nil || bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+			log.Printf("[INFO] Config changed, reloading")
+			if err := m.Reload(); err != nil {
+				log.Printf("[ERROR] Failed to reload config, keeping the current one: %v", err)
+			}
+		}
+	}
+}
+
+// readAll returns the contents of paths joined, or nil if any cannot be read.
+func readAll(paths []string) []byte {
+	var all []byte
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		all = append(all, data...)
+		all = append(all, 0)
+	}
+	return all
+}
+
+// keepRestartOnly copies the settings that are only read at startup from old
+// to cfg, warning about those that changed.
+func keepRestartOnly(old, cfg *config.Config) {
+	if cfg.NodeID != old.NodeID {
+		log.Printf("[WARN] Changing node_id requires a restart. Keeping %q.", old.NodeID)
+		cfg.NodeID = old.NodeID
+	}
+	if fields := changedFields("storage", old.Storage, cfg.Storage); len(fields) > 0 {
+		warnRestartOnly(fields)
+		cfg.Storage = old.Storage
+	}
+	if cfg.System.HealthPort != old.System.HealthPort {
+		log.Printf("[WARN] Changing system.health_port requires a restart. Keeping %d.", old.System.HealthPort)
+		cfg.System.HealthPort = old.System.HealthPort
+	}
+	if fields := changedFields("sinks", old.Sinks, cfg.Sinks); len(fields) > 0 {
+		warnRestartOnly(fields)
+		cfg.Sinks = old.Sinks
+	}
+}
+
+func warnRestartOnly(fields []string) {
+	for _, field := range fields {
+		log.Printf("[WARN] Changing %s requires a restart. Keeping the current value.", field)
+	}
+}
+
+// changedFields returns the YAML paths of the settings that differ between a
+// and b, e.g. "storage.data_path" or "sinks[1].url". Values are not included,
+// as some of them are secrets.
+func changedFields(path string, a, b any) []string {
+	return diffValues(path, reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func diffValues(path string, a, b reflect.Value) []string {
+	if reflect.DeepEqual(a.Interface(), b.Interface()) {
+		return nil
+	}
+	switch a.Kind() {
+	case reflect.Struct:
+		var fields []string
+		for i := 0; i < a.NumField(); i++ {
+			name, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			fields = append(fields, diffValues(path+"."+name, a.Field(i), b.Field(i))...)
+		}
+		return fields
+	case reflect.Slice:
+		if a.Type().Elem().Kind() != reflect.Struct {
+			break
+		}
+		if a.Len() != b.Len() {
+			return []string{fmt.Sprintf("%s (%d entries, was %d)", path, b.Len(), a.Len())}
+		}
+		var fields []string
+		for i := 0; i < a.Len(); i++ {
+			fields = append(fields, diffValues(fmt.Sprintf("%s[%d]", path, i), a.Index(i), b.Index(i))...)
+		}
+		return fields
+	}
+	return []string{path}
+}
+
+func equal(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,30 @@
+package monitor
+
+import (
+	"reflect"
+	"testing"
+
+	"gswarm-sidecar/internal/config"
+)
+
+func TestKeepRestartOnlyNamesChangedFields(t *testing.T) {
+	old := &config.Config{}
+	old.Storage.DataPath = "./data"
+	old.Sinks = []config.SinkConfig{{Type: "stdout"}, {Type: "webhook", URL: "https://a.example"}}
+
+	cfg := &config.Config{}
+	cfg.Storage.DataPath = "./other"
+	cfg.Sinks = []config.SinkConfig{{Type: "stdout"}, {Type: "webhook", URL: "https://b.example", Include: []string{"logs"}}}
+
+	if got, want := changedFields("sinks", old.Sinks, cfg.Sinks), []string{"sinks[1].include", "sinks[1].url"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changed sink fields = %q, want %q", got, want)
+	}
+	if got := changedFields("sinks", old.Sinks, cfg.Sinks[:1]); len(got) != 1 || got[0] != "sinks (1 entries, was 2)" {
+		t.Errorf("removed sink reported as %q", got)
+	}
+
+	keepRestartOnly(old, cfg)
+	if cfg.Storage.DataPath != "./data" || !reflect.DeepEqual(cfg.Sinks, old.Sinks) {
+		t.Errorf("restart-only settings were applied: %+v %+v", cfg.Storage, cfg.Sinks)
+	}
+}
@@ -0,0 +1,109 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	minRestartBackoff = time.Second
+	maxRestartBackoff = 5 * time.Minute
+	// A component that ran this long before failing is restarted after
+	// minRestartBackoff again.
+	stableRunTime = 10 * time.Minute
+)
+
+var errReturned = errors.New("returned before being stopped")
+
+// component is a supervised monitor that runs until its own context is
+// cancelled.
+type component struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startComponent runs start in its own goroutine with a context that can be
+// cancelled separately for a restart. When start panics or returns before
+// the context is cancelled, the crash is recorded in the health status under
+// name and start is called again after an exponential backoff.
+func (m *Monitor) startComponent(name string, start func(ctx context.Context)) *component {
+	ctx, cancel := context.WithCancel(m.ctx)
+	c := &component{cancel: cancel, done: make(chan struct{})}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(c.done)
+		backoff := minRestartBackoff
+		for {
+			began := time.Now()
+			err := run(ctx, start)
+			if ctx.Err() != nil {
+				return
+			}
+			if time.Since(began) >= stableRunTime {
+				backoff = minRestartBackoff
+			}
+			m.processor.Status().ReportCrash(name, err)
+			log.Printf("[ERROR] %s monitor %v, restarting in %v", name, err, backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, maxRestartBackoff)
+		}
+	}()
+	return c
+}
+
+// startService runs one of the core services until m is stopped. Unlike
+// the monitors they may return early by design, e.g. the transmitter without
+// an outbox or the health server when its port is taken, so only a panic
+// restarts them. They have no health status of their own, so the crash is
+// only logged.
+func (m *Monitor) startService(name string, start func(ctx context.Context)) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		backoff := minRestartBackoff
+		for {
+			err := run(m.ctx, start)
+			if err == nil || errors.Is(err, errReturned) {
+				return
+			}
+			log.Printf("[ERROR] %s %v, restarting in %v", name, err, backoff)
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, maxRestartBackoff)
+		}
+	}()
+}
+
+// run calls start and returns why it ended: a recovered panic, errReturned,
+// or nil once ctx is cancelled.
+func run(ctx context.Context, start func(ctx context.Context)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[ERROR] Recovered panic: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("panicked: %v", r)
+		}
+	}()
+	start(ctx)
+	if ctx.Err() == nil {
+		return errReturned
+	}
+	return nil
+}
+
+// stop cancels the component and waits for it to return.
+func (c *component) stop() {
+	c.cancel()
+	<-c.done
+}
@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"strconv"
+
+	"gswarm-sidecar/internal/metrics"
+)
+
+const bytesPerMB = 1024 * 1024
+
+// Metrics returns the registry backing the Prometheus /metrics endpoint.
+func (p *Processor) Metrics() *metrics.Registry {
+	return p.metrics
+}
+
+// ObserveHardware records a hardware sample for local scraping. It is called
+// on every poll, independently of the batched remote upload.
+func (p *Processor) ObserveHardware(hw *HardwareMetrics) {
+	r := p.metrics
+	r.SetGauge("gswarm_cpu_usage_percent", "CPU utilization in percent.", hw.CPU.UsagePercent)
+	r.SetGauge("gswarm_cpu_cores", "Number of CPU cores.", float64(hw.CPU.CoreCount))
+	for i, period := range []string{"1m", "5m", "15m"} {
+		if i < len(hw.CPU.LoadAvg) {
+			r.SetGauge("gswarm_load_average", "System load average.", hw.CPU.LoadAvg[i], "period", period)
+		}
+	}
+
+	r.SetGauge("gswarm_memory_total_bytes", "Total physical memory.", float64(hw.RAM.Total))
+	r.SetGauge("gswarm_memory_used_bytes", "Used physical memory.", float64(hw.RAM.Used))
+	r.SetGauge("gswarm_memory_available_bytes", "Available physical memory.", float64(hw.RAM.Available))
+	r.SetGauge("gswarm_memory_usage_percent", "Physical memory utilization in percent.", hw.RAM.UsagePercent)
+	r.SetGauge("gswarm_swap_total_bytes", "Total swap space.", float64(hw.RAM.SwapTotal))
+	r.SetGauge("gswarm_swap_used_bytes", "Used swap space.", float64(hw.RAM.SwapUsed))
+	r.SetGauge("gswarm_swap_usage_percent", "Swap utilization in percent.", hw.RAM.SwapPercent)
+
+	for _, name := range []string{
+		"gswarm_gpu_utilization_percent", "gswarm_gpu_temperature_celsius",
+		"gswarm_gpu_memory_used_bytes", "gswarm_gpu_memory_total_bytes",
+	} {
+		r.ResetFamily(name)
+	}
+	for _, gpu := range hw.GPU {
+		idx := strconv.Itoa(gpu.Index)
+		r.SetGauge("gswarm_gpu_utilization_percent", "GPU utilization in percent.", gpu.UtilPercent, "gpu", idx)
+		r.SetGauge("gswarm_gpu_temperature_celsius", "GPU temperature.", gpu.TempC, "gpu", idx)
+		r.SetGauge("gswarm_gpu_memory_used_bytes", "Used GPU memory.", gpu.VRAMUsedMB*bytesPerMB, "gpu", idx)
+		r.SetGauge("gswarm_gpu_memory_total_bytes", "Total GPU memory.", gpu.VRAMTotalMB*bytesPerMB, "gpu", idx)
+	}
+}
+
+// ObserveLogEvent counts a parsed log event by type.
+func (p *Processor) ObserveLogEvent(eventType string) {
+	p.metrics.AddCounter("gswarm_log_events_total", "Parsed log events by event type.", 1, "event_type", eventType)
+}
+
+// ObserveLogDrop counts a log event that was not sent on its own, by reason
+// (deduplicated, rate_limited, sampled or filtered).
+func (p *Processor) ObserveLogDrop(reason string) {
+	p.metrics.AddCounter("gswarm_log_events_dropped_total", "Log events not sent on their own (collapsed, sampled out, rate limited or filtered), by reason.", 1, "reason", reason)
+}
+
+// ObserveScrub counts the values replaced by a PII scrubbing detector.
+func (p *Processor) ObserveScrub(detector string, n int) {
+	p.metrics.AddCounter("gswarm_log_scrubbed_total", "Values replaced by PII scrubbing, by detector.", float64(n), "detector", detector)
+}
+
+func (p *Processor) observeBlockchain(bc *BlockchainMetrics) {
+	r := p.metrics
+	r.SetGauge("gswarm_blockchain_block_number", "Latest observed block number.", float64(bc.BlockNumber))
+	r.SetGauge("gswarm_blockchain_participation", "Votes cast across all configured peers.", float64(bc.Participation))
+	r.SetGauge("gswarm_blockchain_rewards", "Total rewards across all configured peers.", float64(bc.TotalRewards))
+	r.SetGauge("gswarm_blockchain_wins", "Total wins across all configured peers.", float64(bc.TotalWins))
+	r.SetGauge("gswarm_blockchain_current_round", "Current swarm round.", float64(bc.CurrentRound))
+	r.SetGauge("gswarm_blockchain_current_stage", "Current swarm stage.", float64(bc.CurrentStage))
+
+	// Peers removed from the config must not linger in the exposition.
+	for _, name := range []string{"gswarm_peer_participation", "gswarm_peer_rewards", "gswarm_peer_wins"} {
+		r.ResetFamily(name)
+	}
+	for _, peer := range bc.Peers {
+		r.SetGauge("gswarm_peer_participation", "Votes cast by peer.", float64(peer.Participation), "peer_id", peer.PeerID)
+		r.SetGauge("gswarm_peer_rewards", "Total rewards of peer.", float64(peer.TotalRewards), "peer_id", peer.PeerID)
+		r.SetGauge("gswarm_peer_wins", "Total wins of peer.", float64(peer.TotalWins), "peer_id", peer.PeerID)
+	}
+	for _, ev := range bc.ContractEvents {
+		r.AddCounter("gswarm_contract_events_total", "Decoded contract events by type.", 1, "event_type", ev.EventType)
+	}
+}
+
+func (p *Processor) observeDHT(dht *DHTMetrics) {
+	p.metrics.SetGauge("gswarm_dht_reachable_peers", "Configured DHT peers that answered multistream-select.", float64(dht.ReachablePeers))
+}
@@ -3,16 +3,33 @@ package processor
 import (
 	"context"
 	"fmt"
+	"log"
+	"sync"
 	"time"
 
 	"gswarm-sidecar/internal/config"
+	"gswarm-sidecar/internal/health"
+	"gswarm-sidecar/internal/metrics"
+	"gswarm-sidecar/internal/sink"
 	"gswarm-sidecar/internal/transmitter"
 )
 
+// Component names reported to the health registry.
+const (
+	ComponentLogs       = "logs"
+	ComponentDHT        = "dht"
+	ComponentBlockchain = "blockchain"
+	ComponentSystem     = "system"
+)
+
 type Processor struct {
 	transmitter *transmitter.Transmitter
 	nodeID      string
+	mu          sync.RWMutex
 	cfg         *config.Config
+	status      *health.Registry
+	metrics     *metrics.Registry
+	sinks       *sink.Dispatcher
 }
 
 type LogMetrics struct {
@@ -21,6 +38,16 @@ type LogMetrics struct {
 	WandbLogs []LogEntry `json:"wandb_logs"`
 }
 
+// LogEvent is a parsed log line as posted to the log ingest API
+// (see docs/logs_outgoing_schema.md).
+type LogEvent struct {
+	NodeID    string                 `json:"node_id"`
+	Timestamp time.Time              `json:"timestamp"`
+	EventType string                 `json:"event_type"`
+	Details   map[string]interface{} `json:"details"`
+	Level     string                 `json:"-"` // level of the log record, used for local filtering only
+}
+
 type LogEntry struct {
 	Timestamp time.Time `json:"timestamp"`
 	Level     string    `json:"level"`
@@ -28,10 +55,14 @@ type LogEntry struct {
 	Source    string    `json:"source"`
 }
 
+// DHTMetrics describes the configured DHT peers: the bootstrap peers and the
+// local peer on DHT.Port. ReachablePeers counts those that completed a
+// multistream-select handshake in the last poll and ReachablePeerIDs lists
+// them; neither reflects the peers the node itself is connected to.
 type DHTMetrics struct {
-	PeerCount    int                    `json:"peer_count"`
-	ActivePeers  []string               `json:"active_peers"`
-	NetworkStats map[string]interface{} `json:"network_stats"`
+	ReachablePeers   int                    `json:"reachable_peers"`
+	ReachablePeerIDs []string               `json:"reachable_peer_ids"`
+	NetworkStats     map[string]interface{} `json:"network_stats"`
 }
 
 type BlockchainMetrics struct {
@@ -42,14 +73,43 @@ type BlockchainMetrics struct {
 	Participation  uint64          `json:"participation"`
 	TotalRewards   int64           `json:"total_rewards"`
 	TotalWins      uint64          `json:"total_wins"`
+
+	Peers []PeerStats `json:"peers"`
+
+	CurrentRound uint64             `json:"current_round"`
+	CurrentStage uint64             `json:"current_stage"`
+	StageCount   uint64             `json:"stage_count"`
+	RoundHistory []RoundStageStatus `json:"round_history"`
+}
+
+// PeerStats holds the on-chain counters of a single RL-Swarm peer.
+type PeerStats struct {
+	PeerID        string `json:"peer_id"`
+	EOA           string `json:"eoa,omitempty"`
+	Participation uint64 `json:"participation"`
+	TotalRewards  int64  `json:"total_rewards"`
+	TotalWins     uint64 `json:"total_wins"`
+}
+
+// RoundStageStatus records whether the node's peer submitted a reward for a
+// round stage that has closed.
+type RoundStageStatus struct {
+	RoundNumber uint64    `json:"round_number"`
+	StageNumber uint64    `json:"stage_number"`
+	PeerID      string    `json:"peer_id"`
+	Submitted   bool      `json:"submitted"`
+	Reward      string    `json:"reward,omitempty"`
+	ClosedAt    time.Time `json:"closed_at"`
 }
 
 type ContractEvent struct {
-	EventType string                 `json:"event_type"`
-	Timestamp time.Time              `json:"timestamp"`
-	Data      map[string]interface{} `json:"data"`
-	BlockHash string                 `json:"block_hash"`
-	TxHash    string                 `json:"tx_hash"`
+	EventType   string                 `json:"event_type"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Data        map[string]interface{} `json:"data"`
+	BlockHash   string                 `json:"block_hash"`
+	TxHash      string                 `json:"tx_hash"`
+	BlockNumber uint64                 `json:"block_number"`
+	LogIndex    uint                   `json:"log_index"`
 }
 
 type SystemMetrics struct {
@@ -74,9 +134,10 @@ type GPUMetrics struct {
 }
 
 type CPUMetrics struct {
-	UsagePercent float64 `json:"usage_percent"`
-	CoreCount    int     `json:"core_count"`
-	Temperature  float64 `json:"temperature"`
+	UsagePercent float64   `json:"usage_percent"`
+	CoreCount    int       `json:"core_count"`
+	Temperature  float64   `json:"temperature"`
+	LoadAvg      []float64 `json:"load_avg,omitempty"`
 }
 
 type MemoryMetrics struct {
@@ -104,13 +165,77 @@ type NetworkMetrics struct {
 }
 
 func New(transmitter *transmitter.Transmitter, nodeID string, cfg *config.Config) *Processor {
+	status := health.NewRegistry()
+	status.SetQueueDepthFunc(transmitter.Backlog)
+
+	registry := metrics.NewRegistry()
+	registry.OnScrape(func() {
+		registry.SetGauge("gswarm_outbox_pending", "Payloads waiting in the outbox.", float64(transmitter.Backlog()))
+	})
+
+	sinks, err := sink.FromConfig(cfg, transmitter)
+	if err != nil {
+		log.Printf("[processor] Invalid sink config, using the gswarm API only: %v", err)
+		sinks = sink.NewDispatcher()
+		sinks.Add(sink.NewAPISink(transmitter), sink.Options{Primary: true})
+	}
+
 	return &Processor{
 		transmitter: transmitter,
 		nodeID:      nodeID,
 		cfg:         cfg,
+		status:      status,
+		metrics:     registry,
+		sinks:       sinks,
 	}
 }
 
+// SetConfig applies a reloaded config to subsequent payloads: endpoints and
+// tokens are read from it, node ID and sinks are kept.
+func (p *Processor) SetConfig(cfg *config.Config) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cfg = cfg
+	p.transmitter.SetConfig(cfg)
+}
+
+func (p *Processor) config() *config.Config {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cfg
+}
+
+// Start runs the sink workers until ctx is cancelled.
+func (p *Processor) Start(ctx context.Context) {
+	p.sinks.Start(ctx)
+}
+
+// publish fans a payload out to the configured sinks and waits for the
+// primary sink's delivery result. Other sinks are fed asynchronously.
+func (p *Processor) publish(ctx context.Context, kind, endpoint string, useJWT bool, payload interface{}) error {
+	done := make(chan error, 1)
+	p.sinks.Publish(sink.Record{
+		Kind:      kind,
+		Endpoint:  endpoint,
+		UseJWT:    useJWT,
+		Timestamp: time.Now(),
+		Payload:   payload,
+		Ack:       func(err error) { done <- err },
+	})
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status returns the registry monitors report their health to.
+func (p *Processor) Status() *health.Registry {
+	return p.status
+}
+
 func (p *Processor) ProcessLogs(ctx context.Context, metrics *LogMetrics) error {
 	data := &transmitter.MetricsData{
 		NodeID:      p.nodeID,
@@ -123,26 +248,41 @@ func (p *Processor) ProcessLogs(ctx context.Context, metrics *LogMetrics) error
 		},
 	}
 
-	err := p.transmitter.SendMetrics(ctx, data)
+	err := p.publish(ctx, data.MetricsType, p.config().API.MetricsEndpoint, false, data)
+	p.status.Report(ComponentLogs, err)
 	if err != nil {
 		return fmt.Errorf("failed to send log metrics: %w", err)
 	}
 	return nil
 }
 
+// ProcessLogBatch sends a batch of parsed log events to the log ingest API. It
+// returns once the primary sink has delivered or durably queued the batch.
+func (p *Processor) ProcessLogBatch(ctx context.Context, events []LogEvent) error {
+	cfg := p.config()
+	err := p.publish(ctx, "logs", cfg.LogMonitoring.APIEndpoint, true, events)
+	p.status.Report(ComponentLogs, err)
+	if err != nil {
+		return fmt.Errorf("failed to send log events: %w", err)
+	}
+	return nil
+}
+
 func (p *Processor) ProcessDHT(ctx context.Context, metrics *DHTMetrics) error {
+	p.observeDHT(metrics)
 	data := &transmitter.MetricsData{
 		NodeID:      p.nodeID,
 		Timestamp:   time.Now(),
 		MetricsType: "dht",
 		Data: map[string]interface{}{
-			"peer_count":    metrics.PeerCount,
-			"active_peers":  metrics.ActivePeers,
-			"network_stats": metrics.NetworkStats,
+			"reachable_peers":    metrics.ReachablePeers,
+			"reachable_peer_ids": metrics.ReachablePeerIDs,
+			"network_stats":      metrics.NetworkStats,
 		},
 	}
 
-	err := p.transmitter.SendMetrics(ctx, data)
+	err := p.publish(ctx, data.MetricsType, p.config().API.MetricsEndpoint, false, data)
+	p.status.Report(ComponentDHT, err)
 	if err != nil {
 		return fmt.Errorf("failed to send DHT metrics: %w", err)
 	}
@@ -150,6 +290,7 @@ func (p *Processor) ProcessDHT(ctx context.Context, metrics *DHTMetrics) error {
 }
 
 func (p *Processor) ProcessBlockchain(ctx context.Context, metrics *BlockchainMetrics) error {
+	p.observeBlockchain(metrics)
 	data := &transmitter.MetricsData{
 		NodeID:      p.nodeID,
 		Timestamp:   time.Now(),
@@ -161,10 +302,17 @@ func (p *Processor) ProcessBlockchain(ctx context.Context, metrics *BlockchainMe
 			"participation":   metrics.Participation,
 			"total_rewards":   metrics.TotalRewards,
 			"total_wins":      metrics.TotalWins,
+			"peers":           metrics.Peers,
+			"current_round":   metrics.CurrentRound,
+			"current_stage":   metrics.CurrentStage,
+			"stage_count":     metrics.StageCount,
+			"round_history":   metrics.RoundHistory,
 		},
 	}
 
-	err := p.transmitter.SendJSON(ctx, p.cfg.API.BlockchainLatestEndpoint, data, p.cfg.JWTToken)
+	cfg := p.config()
+	err := p.publish(ctx, data.MetricsType, cfg.API.BlockchainLatestEndpoint, true, data)
+	p.status.Report(ComponentBlockchain, err)
 	if err != nil {
 		return fmt.Errorf("failed to send blockchain metrics: %w", err)
 	}
@@ -184,7 +332,8 @@ func (p *Processor) ProcessSystem(ctx context.Context, metrics *SystemMetrics) e
 		},
 	}
 
-	err := p.transmitter.SendMetrics(ctx, data)
+	err := p.publish(ctx, data.MetricsType, p.config().API.MetricsEndpoint, false, data)
+	p.status.Report(ComponentSystem, err)
 	if err != nil {
 		return fmt.Errorf("failed to send system metrics: %w", err)
 	}
@@ -203,7 +352,8 @@ func (p *Processor) ProcessHardware(ctx context.Context, metrics *HardwareMetric
 		},
 	}
 
-	err := p.transmitter.SendMetrics(ctx, data)
+	err := p.publish(ctx, data.MetricsType, p.config().API.MetricsEndpoint, false, data)
+	p.status.Report(ComponentSystem, err)
 	if err != nil {
 		return fmt.Errorf("failed to send hardware metrics: %w", err)
 	}
@@ -218,7 +368,7 @@ func (p *Processor) SendHealth(ctx context.Context, status, details string) erro
 		Details:   details,
 	}
 
-	err := p.transmitter.SendHealth(ctx, data)
+	err := p.publish(ctx, "health", p.config().API.HealthEndpoint, false, data)
 	if err != nil {
 		return fmt.Errorf("failed to send health data: %w", err)
 	}
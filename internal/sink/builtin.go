@@ -0,0 +1,165 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gswarm-sidecar/internal/transmitter"
+)
+
+const defaultWebhookTimeout = 10 * time.Second
+
+// envelope is the JSON shape written by the file, stdout and webhook sinks.
+type envelope struct {
+	Kind      string      `json:"kind"`
+	Timestamp time.Time   `json:"timestamp"`
+	Payload   interface{} `json:"payload"`
+}
+
+func toEnvelope(rec *Record) envelope {
+	return envelope{Kind: rec.Kind, Timestamp: rec.Timestamp, Payload: rec.Payload}
+}
+
+// APISink delivers records to the gswarm API through the transmitter, which
+// handles retries, auth and the persistent outbox.
+type APISink struct {
+	transmitter *transmitter.Transmitter
+}
+
+func NewAPISink(t *transmitter.Transmitter) *APISink {
+	return &APISink{transmitter: t}
+}
+
+func (s *APISink) Name() string { return "gswarm" }
+
+func (s *APISink) Write(ctx context.Context, records []Record) error {
+	var firstErr error
+	for i := range records {
+		rec := &records[i]
+		if err := s.transmitter.SendJSON(ctx, rec.Endpoint, rec.Payload, rec.UseJWT); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to send %s record: %w", rec.Kind, err)
+		}
+	}
+	return firstErr
+}
+
+// FileSink appends records as JSON lines to a local file.
+type FileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (s *FileSink) Name() string { return "file:" + s.path }
+
+func (s *FileSink) Write(_ context.Context, records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create sink dir: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open sink file: %w", err)
+	}
+	if err := writeJSONLines(f, records); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close sink file: %w", err)
+	}
+	return nil
+}
+
+// StdoutSink prints records as JSON lines.
+type StdoutSink struct {
+	out io.Writer
+	mu  sync.Mutex
+}
+
+func NewStdoutSink() *StdoutSink {
+	return &StdoutSink{out: os.Stdout}
+}
+
+func (s *StdoutSink) Name() string { return "stdout" }
+
+func (s *StdoutSink) Write(_ context.Context, records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeJSONLines(s.out, records)
+}
+
+// WebhookSink POSTs each batch as a JSON array to an arbitrary URL.
+type WebhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func NewWebhookSink(url string, headers map[string]string, timeout time.Duration) *WebhookSink {
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &WebhookSink{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *WebhookSink) Name() string { return "webhook:" + s.url }
+
+func (s *WebhookSink) Write(ctx context.Context, records []Record) error {
+	batch := make([]envelope, len(records))
+	for i := range records {
+		batch[i] = toEnvelope(&records[i])
+	}
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook batch: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func writeJSONLines(w io.Writer, records []Record) error {
+	enc := json.NewEncoder(w)
+	for i := range records {
+		if err := enc.Encode(toEnvelope(&records[i])); err != nil {
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package sink
+
+import (
+	"fmt"
+	"time"
+
+	"gswarm-sidecar/internal/config"
+	"gswarm-sidecar/internal/transmitter"
+)
+
+// Sink types accepted in config.
+const (
+	TypeGswarm  = "gswarm"
+	TypeFile    = "file"
+	TypeStdout  = "stdout"
+	TypeWebhook = "webhook"
+)
+
+// FromConfig builds a dispatcher for the configured sinks. Without any sinks
+// configured, records go to the gswarm API only. The first gswarm sink is
+// primary; otherwise the first sink listed is. The primary sink must accept
+// every kind, as records it skipped would be acknowledged undelivered.
+func FromConfig(cfg *config.Config, t *transmitter.Transmitter) (*Dispatcher, error) {
+	sinks := cfg.Sinks
+	if len(sinks) == 0 {
+		sinks = []config.SinkConfig{{Type: TypeGswarm}}
+	}
+
+	primary := 0
+	for i, sc := range sinks {
+		if sc.Type == TypeGswarm {
+			primary = i
+			break
+		}
+	}
+
+	if sc := sinks[primary]; len(sc.Include) > 0 || len(sc.Exclude) > 0 {
+		return nil, fmt.Errorf("sinks[%d]: the primary sink must deliver every metrics type, without include or exclude", primary)
+	}
+
+	d := NewDispatcher()
+	for i, sc := range sinks {
+		s, err := build(sc, t)
+		if err != nil {
+			return nil, fmt.Errorf("sinks[%d]: %w", i, err)
+		}
+		d.Add(s, Options{
+			Include:       sc.Include,
+			Exclude:       sc.Exclude,
+			BatchSize:     sc.BatchSize,
+			FlushInterval: time.Duration(sc.FlushInterval) * time.Second,
+			QueueSize:     sc.QueueSize,
+			Primary:       i == primary,
+		})
+	}
+	return d, nil
+}
+
+func build(sc config.SinkConfig, t *transmitter.Transmitter) (Sink, error) {
+	switch sc.Type {
+	case TypeGswarm:
+		return NewAPISink(t), nil
+	case TypeFile:
+		if sc.Path == "" {
+			return nil, fmt.Errorf("file sink requires path")
+		}
+		return NewFileSink(sc.Path), nil
+	case TypeStdout:
+		return NewStdoutSink(), nil
+	case TypeWebhook:
+		if sc.URL == "" {
+			return nil, fmt.Errorf("webhook sink requires url")
+		}
+		return NewWebhookSink(sc.URL, sc.Headers, time.Duration(sc.Timeout)*time.Second), nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", sc.Type)
+	}
+}
@@ -0,0 +1,235 @@
+// Package sink fans processed records out to one or more destinations, each
+// with its own filtering, batching and queue so a slow sink cannot stall the
+// others.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQueueSize     = 1000
+	defaultBatchSize     = 1
+	defaultFlushInterval = 5 * time.Second
+	shutdownFlushTimeout = 10 * time.Second
+)
+
+// ErrQueueFull is reported to a record's Ack when its sink is backlogged.
+var ErrQueueFull = errors.New("sink queue full")
+
+// Record is a single processed payload.
+type Record struct {
+	// Kind is the metrics type, e.g. "logs", "hardware" or "blockchain".
+	Kind string
+	// Endpoint addresses the record on the gswarm API. UseJWT authenticates
+	// it with jwt_token instead of api.auth_token.
+	Endpoint  string
+	UseJWT    bool
+	Timestamp time.Time
+	Payload   interface{}
+	// Ack, if set, is called once with the primary sink's delivery result.
+	Ack func(error)
+}
+
+func (r *Record) ack(err error) {
+	if r.Ack != nil {
+		r.Ack(err)
+	}
+}
+
+// Sink is a destination for records.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, records []Record) error
+}
+
+// Options controls how a sink is fed.
+type Options struct {
+	Include       []string // kinds to deliver; empty means all
+	Exclude       []string // kinds to skip
+	BatchSize     int
+	FlushInterval time.Duration
+	QueueSize     int
+	// Primary marks the sink whose delivery result is reported to Record.Ack.
+	// It should accept every kind: records it skips are acknowledged at once.
+	Primary bool
+}
+
+func (o Options) accepts(kind string) bool {
+	for _, k := range o.Exclude {
+		if k == kind {
+			return false
+		}
+	}
+	if len(o.Include) == 0 {
+		return true
+	}
+	for _, k := range o.Include {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+type worker struct {
+	sink  Sink
+	opts  Options
+	queue chan Record
+}
+
+// Dispatcher routes records to every sink that accepts them.
+type Dispatcher struct {
+	workers []*worker
+	primary bool
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// Add registers a sink. It must be called before Start.
+func (d *Dispatcher) Add(s Sink, opts Options) {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.Primary {
+		d.primary = true
+	}
+	d.workers = append(d.workers, &worker{
+		sink:  s,
+		opts:  opts,
+		queue: make(chan Record, opts.QueueSize),
+	})
+}
+
+// Publish queues rec on every accepting sink without blocking. When no
+// primary sink takes the record it is acknowledged immediately.
+//
+// The payload is snapshotted as JSON first, so sinks that write it later
+// never share maps the caller may still change, e.g. when it retries.
+func (d *Dispatcher) Publish(rec Record) {
+	payload, err := json.Marshal(rec.Payload)
+	if err != nil {
+		rec.ack(fmt.Errorf("failed to marshal %s record: %w", rec.Kind, err))
+		return
+	}
+	rec.Payload = json.RawMessage(payload)
+
+	acked := false
+	for _, w := range d.workers {
+		if !w.opts.accepts(rec.Kind) {
+			continue
+		}
+		r := rec
+		if !w.opts.Primary {
+			r.Ack = nil
+		}
+		select {
+		case w.queue <- r:
+			if w.opts.Primary {
+				acked = true
+			}
+		default:
+			log.Printf("[sink] %s queue full, dropping %s record", w.sink.Name(), rec.Kind)
+			if w.opts.Primary {
+				rec.ack(ErrQueueFull)
+				acked = true
+			}
+		}
+	}
+	if !acked {
+		rec.ack(nil)
+	}
+}
+
+// Start runs every sink worker until ctx is cancelled, then flushes what is
+// still queued.
+func (d *Dispatcher) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, w := range d.workers {
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			w.run(ctx)
+		}(w)
+	}
+	wg.Wait()
+}
+
+func (w *worker) run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, w.opts.BatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			w.drain(&batch)
+			return
+		case rec := <-w.queue:
+			batch = append(batch, rec)
+			if len(batch) >= w.opts.BatchSize {
+				w.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// drain flushes the pending batch and queue on shutdown.
+func (w *worker) drain(batch *[]Record) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownFlushTimeout)
+	defer cancel()
+	for {
+		select {
+		case rec := <-w.queue:
+			*batch = append(*batch, rec)
+		default:
+			if len(*batch) > 0 {
+				w.flush(ctx, *batch)
+			}
+			return
+		}
+	}
+}
+
+func (w *worker) flush(ctx context.Context, batch []Record) {
+	err := w.write(ctx, batch)
+	if err != nil {
+		log.Printf("[sink] %s failed to write %d records: %v", w.sink.Name(), len(batch), err)
+	}
+	for i := range batch {
+		batch[i].ack(err)
+	}
+}
+
+// write calls the sink, turning a panic into a write error so the worker
+// keeps running and the batch is still acknowledged.
+func (w *worker) write(ctx context.Context, batch []Record) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[sink] %s panicked: %v\n%s", w.sink.Name(), r, debug.Stack())
+			err = fmt.Errorf("%s sink panicked: %v", w.sink.Name(), r)
+		}
+	}()
+	return w.sink.Write(ctx, batch)
+}
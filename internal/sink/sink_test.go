@@ -0,0 +1,106 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gswarm-sidecar/internal/config"
+)
+
+type recordingSink struct {
+	mu      sync.Mutex
+	written []string
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Write(_ context.Context, records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rec := range records {
+		data, err := json.Marshal(rec.Payload)
+		if err != nil {
+			return err
+		}
+		s.written = append(s.written, string(data))
+	}
+	return nil
+}
+
+func TestPublishSnapshotsPayload(t *testing.T) {
+	primary, other := &recordingSink{}, &recordingSink{}
+	d := NewDispatcher()
+	d.Add(primary, Options{Primary: true})
+	d.Add(other, Options{FlushInterval: time.Hour, BatchSize: 10})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Start(ctx)
+		close(done)
+	}()
+
+	details := map[string]interface{}{"message": "original"}
+	acked := make(chan error, 1)
+	d.Publish(Record{Kind: "logs", Payload: []map[string]interface{}{details}, Ack: func(err error) { acked <- err }})
+	if err := <-acked; err != nil {
+		t.Fatal(err)
+	}
+	// The caller owns its maps again once Publish returns.
+	details["message"] = "changed"
+
+	cancel()
+	<-done
+	want := `[{"message":"original"}]`
+	for name, s := range map[string]*recordingSink{"primary": primary, "other": other} {
+		if len(s.written) != 1 || s.written[0] != want {
+			t.Errorf("%s sink wrote %q, want %q", name, s.written, want)
+		}
+	}
+}
+
+type panickingSink struct{}
+
+func (panickingSink) Name() string { return "panicking" }
+
+func (panickingSink) Write(context.Context, []Record) error { panic("boom") }
+
+func TestPanickingSinkFailsWrite(t *testing.T) {
+	d := NewDispatcher()
+	d.Add(panickingSink{}, Options{Primary: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Start(ctx)
+
+	for i := 0; i < 2; i++ {
+		acked := make(chan error, 1)
+		d.Publish(Record{Kind: "logs", Payload: i, Ack: func(err error) { acked <- err }})
+		if err := <-acked; err == nil {
+			t.Fatalf("record %d acknowledged without error", i)
+		}
+	}
+}
+
+func TestFromConfigRequiresUnfilteredPrimary(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		sinks []config.SinkConfig
+		ok    bool
+	}{
+		{[]config.SinkConfig{{Type: TypeGswarm, Exclude: []string{"logs"}}, {Type: TypeStdout}}, false},
+		{[]config.SinkConfig{{Type: TypeFile, Path: filepath.Join(dir, "a.jsonl"), Include: []string{"logs"}}}, false},
+		// Only the primary sink must take every kind.
+		{[]config.SinkConfig{{Type: TypeStdout, Include: []string{"dht"}}, {Type: TypeGswarm}}, true},
+		{[]config.SinkConfig{{Type: TypeFile, Path: filepath.Join(dir, "b.jsonl")}, {Type: TypeStdout, Exclude: []string{"logs"}}}, true},
+	} {
+		_, err := FromConfig(&config.Config{Sinks: tc.sinks}, nil)
+		if (err == nil) != tc.ok {
+			t.Errorf("sinks %+v: err = %v", tc.sinks, err)
+		}
+	}
+}
@@ -32,21 +32,24 @@ func New(cfg *config.Config, processor *processor.Processor) *Monitor {
 func (m *Monitor) Start(ctx context.Context) {
 	log.Println("Starting hardware monitoring...")
 
-	// Start hardware monitoring goroutine
-	go m.startHardwareMonitor(ctx)
+	// Run in the caller's goroutine, so a panic reaches the supervisor.
+	m.startHardwareMonitor(ctx)
 
 	// TODO: Implement other system monitoring
 	// - Monitor Docker containers
 	// - Health check endpoints
 
-	<-ctx.Done()
 	log.Println("Hardware monitoring stopped")
 }
 
 func (m *Monitor) startHardwareMonitor(ctx context.Context) {
-	ticker := time.NewTicker(time.Duration(m.cfg.System.PollInterval) * time.Second)
+	pollInterval := time.Duration(m.cfg.System.PollInterval) * time.Second
+	ticker := time.NewTicker(pollInterval)
 	defer ticker.Stop()
 
+	// A batch is sent every BatchSize polls; allow three batches before going stale.
+	m.processor.Status().Register(processor.ComponentSystem, 3*time.Duration(m.cfg.System.BatchSize)*pollInterval)
+
 	var batch []map[string]interface{}
 
 	for {
@@ -60,6 +63,8 @@ func (m *Monitor) startHardwareMonitor(ctx context.Context) {
 		case <-ticker.C:
 			metrics := m.collectHardwareMetrics()
 			if metrics != nil {
+				m.processor.ObserveHardware(toHardwareMetrics(metrics))
+
 				event := map[string]interface{}{
 					"type":      "hardware_snapshot",
 					"timestamp": time.Now().UTC().Format(time.RFC3339),
@@ -82,14 +87,14 @@ func (m *Monitor) collectHardwareMetrics() map[string]interface{} {
 	metrics := make(map[string]interface{})
 
 	// Collect CPU metrics
-	if m.cfg.System.EnableCPU {
+	if config.Enabled(m.cfg.System.EnableCPU) {
 		if cpuMetrics := m.collectCPUMetrics(); cpuMetrics != nil {
 			metrics["cpu"] = cpuMetrics
 		}
 	}
 
 	// Collect RAM metrics
-	if m.cfg.System.EnableRAM {
+	if config.Enabled(m.cfg.System.EnableRAM) {
 		if ramMetrics := m.collectRAMMetrics(); ramMetrics != nil {
 			metrics["ram"] = ramMetrics
 		}
@@ -223,62 +228,8 @@ func (m *Monitor) sendHardwareBatch(batch []map[string]interface{}) {
 	// Process the batch to extract metrics
 	for _, event := range batch {
 		if metrics, ok := event["metrics"].(map[string]interface{}); ok {
-			// Extract CPU metrics from the first event (they should be consistent)
-			if cpuData, ok := metrics["cpu"].(map[string]interface{}); ok {
-				if percent, ok := cpuData["percent"].(float64); ok {
-					hardwareMetrics.CPU.UsagePercent = percent
-				}
-				if cores, ok := cpuData["cores"].(int); ok {
-					hardwareMetrics.CPU.CoreCount = cores
-				}
-			}
-
-			// Extract RAM metrics
-			if ramData, ok := metrics["ram"].(map[string]interface{}); ok {
-				if total, ok := ramData["total_mb"].(uint64); ok {
-					hardwareMetrics.RAM.Total = total * 1024 * 1024 // Convert back to bytes
-				}
-				if used, ok := ramData["used_mb"].(uint64); ok {
-					hardwareMetrics.RAM.Used = used * 1024 * 1024 // Convert back to bytes
-				}
-				if percent, ok := ramData["percent_used"].(float64); ok {
-					hardwareMetrics.RAM.UsagePercent = percent
-				}
-				// Extract swap memory data
-				if swapTotal, ok := ramData["swap_total_mb"].(uint64); ok {
-					hardwareMetrics.RAM.SwapTotal = swapTotal * 1024 * 1024 // Convert back to bytes
-				}
-				if swapUsed, ok := ramData["swap_used_mb"].(uint64); ok {
-					hardwareMetrics.RAM.SwapUsed = swapUsed * 1024 * 1024 // Convert back to bytes
-				}
-				if swapPercent, ok := ramData["swap_percent_used"].(float64); ok {
-					hardwareMetrics.RAM.SwapPercent = swapPercent
-				}
-			}
-
-			// Extract GPU metrics
-			if gpuData, ok := metrics["gpu"].([]map[string]interface{}); ok {
-				for _, gpu := range gpuData {
-					if index, ok := gpu["index"].(int); ok {
-						gpuMetric := processor.GPUMetrics{Index: index}
-
-						if util, ok := gpu["util_percent"].(float64); ok {
-							gpuMetric.UtilPercent = util
-						}
-						if temp, ok := gpu["temp_c"].(float64); ok {
-							gpuMetric.TempC = temp
-						}
-						if vramUsed, ok := gpu["vram_used_mb"].(float64); ok {
-							gpuMetric.VRAMUsedMB = vramUsed
-						}
-						if vramTotal, ok := gpu["vram_total_mb"].(float64); ok {
-							gpuMetric.VRAMTotalMB = vramTotal
-						}
-
-						hardwareMetrics.GPU = append(hardwareMetrics.GPU, gpuMetric)
-					}
-				}
-			}
+			// Later snapshots overwrite earlier ones so the latest values are sent
+			hardwareMetrics = toHardwareMetrics(metrics)
 		}
 	}
 
@@ -293,3 +244,74 @@ func (m *Monitor) sendHardwareBatch(batch []map[string]interface{}) {
 		log.Printf("Sent hardware metrics batch with %d events", len(batch))
 	}
 }
+
+// toHardwareMetrics converts a collected hardware snapshot into the typed
+// structure used by the processor.
+func toHardwareMetrics(metrics map[string]interface{}) *processor.HardwareMetrics {
+	hardwareMetrics := &processor.HardwareMetrics{}
+
+	// Extract CPU metrics
+	if cpuData, ok := metrics["cpu"].(map[string]interface{}); ok {
+		if percent, ok := cpuData["percent"].(float64); ok {
+			hardwareMetrics.CPU.UsagePercent = percent
+		}
+		if cores, ok := cpuData["cores"].(int); ok {
+			hardwareMetrics.CPU.CoreCount = cores
+		}
+		if loadAvg, ok := cpuData["load_avg"].([]float64); ok {
+			hardwareMetrics.CPU.LoadAvg = loadAvg
+		}
+	}
+
+	// Extract RAM metrics
+	if ramData, ok := metrics["ram"].(map[string]interface{}); ok {
+		if total, ok := ramData["total_mb"].(uint64); ok {
+			hardwareMetrics.RAM.Total = total * 1024 * 1024 // Convert back to bytes
+		}
+		if used, ok := ramData["used_mb"].(uint64); ok {
+			hardwareMetrics.RAM.Used = used * 1024 * 1024 // Convert back to bytes
+		}
+		if available, ok := ramData["available_mb"].(uint64); ok {
+			hardwareMetrics.RAM.Available = available * 1024 * 1024 // Convert back to bytes
+		}
+		if percent, ok := ramData["percent_used"].(float64); ok {
+			hardwareMetrics.RAM.UsagePercent = percent
+		}
+		// Extract swap memory data
+		if swapTotal, ok := ramData["swap_total_mb"].(uint64); ok {
+			hardwareMetrics.RAM.SwapTotal = swapTotal * 1024 * 1024 // Convert back to bytes
+		}
+		if swapUsed, ok := ramData["swap_used_mb"].(uint64); ok {
+			hardwareMetrics.RAM.SwapUsed = swapUsed * 1024 * 1024 // Convert back to bytes
+		}
+		if swapPercent, ok := ramData["swap_percent_used"].(float64); ok {
+			hardwareMetrics.RAM.SwapPercent = swapPercent
+		}
+	}
+
+	// Extract GPU metrics
+	if gpuData, ok := metrics["gpu"].([]map[string]interface{}); ok {
+		for _, gpu := range gpuData {
+			if index, ok := gpu["index"].(int); ok {
+				gpuMetric := processor.GPUMetrics{Index: index}
+
+				if util, ok := gpu["util_percent"].(float64); ok {
+					gpuMetric.UtilPercent = util
+				}
+				if temp, ok := gpu["temp_c"].(float64); ok {
+					gpuMetric.TempC = temp
+				}
+				if vramUsed, ok := gpu["vram_used_mb"].(float64); ok {
+					gpuMetric.VRAMUsedMB = vramUsed
+				}
+				if vramTotal, ok := gpu["vram_total_mb"].(float64); ok {
+					gpuMetric.VRAMTotalMB = vramTotal
+				}
+
+				hardwareMetrics.GPU = append(hardwareMetrics.GPU, gpuMetric)
+			}
+		}
+	}
+
+	return hardwareMetrics
+}
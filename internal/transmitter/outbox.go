@@ -0,0 +1,348 @@
+package transmitter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	outboxFileName   = "outbox.jsonl"
+	outboxAckName    = "outbox.ack"
+	outboxDirPerm    = 0o700
+	outboxFilePerm   = 0o600           // payloads may carry node identifiers
+	compactThreshold = 4 * 1024 * 1024 // rewrite the journal once this many bytes are acked
+)
+
+// OutboxRecord is a single queued payload waiting for delivery. Tokens are
+// not stored; UseJWT selects the one from the config at send time.
+type OutboxRecord struct {
+	Endpoint   string          `json:"endpoint"`
+	UseJWT     bool            `json:"use_jwt,omitempty"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+	Payload    json.RawMessage `json:"payload"`
+
+	// LegacyAuthToken is the token written by older versions, which always
+	// stored the jwt_token. It is only read.
+	LegacyAuthToken string `json:"auth_token,omitempty"`
+}
+
+// Outbox is a persistent, append-only queue of payloads. Records are appended
+// to a JSON-lines journal and an ack file tracks the byte offset of the first
+// undelivered record, so the queue survives restarts and drains in order.
+type Outbox struct {
+	mu       sync.Mutex
+	path     string
+	ackPath  string
+	file     *os.File
+	ack      int64 // offset of the oldest pending record
+	size     int64 // current journal size
+	pending  int
+	dropped  uint64
+	maxBytes int64
+	maxAge   time.Duration
+	notify   chan struct{}
+}
+
+// OpenOutbox opens (or creates) the outbox stored in dir.
+func OpenOutbox(dir string, maxBytes int64, maxAge time.Duration) (*Outbox, error) {
+	if err := os.MkdirAll(dir, outboxDirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create outbox dir: %w", err)
+	}
+
+	o := &Outbox{
+		path:     filepath.Join(dir, outboxFileName),
+		ackPath:  filepath.Join(dir, outboxAckName),
+		maxBytes: maxBytes,
+		maxAge:   maxAge,
+		notify:   make(chan struct{}, 1),
+	}
+
+	file, err := os.OpenFile(o.path, os.O_CREATE|os.O_RDWR, outboxFilePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox journal: %w", err)
+	}
+	o.file = file
+	// Journals created by older versions were world-readable.
+	if err := file.Chmod(outboxFilePerm); err != nil {
+		log.Printf("[outbox] Failed to restrict permissions of %s: %v", o.path, err)
+	}
+
+	if err := o.recover(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return o, nil
+}
+
+// recover restores the ack offset, drops a torn trailing record left by a
+// crash mid-write and counts the pending records.
+func (o *Outbox) recover() error {
+	if data, err := os.ReadFile(o.ackPath); err == nil {
+		if v, perr := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); perr == nil {
+			o.ack = v
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read outbox ack: %w", err)
+	}
+
+	fi, err := o.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat outbox journal: %w", err)
+	}
+	if o.ack > fi.Size() || o.ack < 0 {
+		o.ack = 0
+	}
+
+	if _, err := o.file.Seek(o.ack, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek outbox journal: %w", err)
+	}
+	reader := bufio.NewReader(o.file)
+	end := o.ack
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			// A partial line without a newline is a torn write; discard it.
+			break
+		}
+		end += int64(len(line))
+		o.pending++
+	}
+	if end != fi.Size() {
+		log.Printf("[outbox] Truncating torn record at offset %d in %s", end, o.path)
+		if err := o.file.Truncate(end); err != nil {
+			return fmt.Errorf("failed to truncate outbox journal: %w", err)
+		}
+	}
+	o.size = end
+	return nil
+}
+
+// Enqueue appends a record to the journal and syncs it to disk. When the
+// journal exceeds its size cap the oldest records are evicted to make room.
+func (o *Outbox) Enqueue(rec *OutboxRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox record: %w", err)
+	}
+	line = append(line, '\n')
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.maxBytes > 0 {
+		for o.pending > 0 && o.size-o.ack+int64(len(line)) > o.maxBytes {
+			_, next, perr := o.peekLocked()
+			if perr != nil {
+				return perr
+			}
+			o.dropped++
+			if err := o.ackLocked(next); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := o.file.WriteAt(line, o.size); err != nil {
+		return fmt.Errorf("failed to append outbox record: %w", err)
+	}
+	if err := o.file.Sync(); err != nil {
+		// Leave the record out of the queue.
+		_ = o.file.Truncate(o.size)
+		return fmt.Errorf("failed to sync outbox journal: %w", err)
+	}
+	o.size += int64(len(line))
+	o.pending++
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Peek returns the oldest pending record and the offset to pass to Ack once it
+// has been delivered. Records older than the age cap are dropped on the way.
+func (o *Outbox) Peek() (*OutboxRecord, int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for o.pending > 0 {
+		rec, next, err := o.peekLocked()
+		if err != nil {
+			return nil, 0, err
+		}
+		if o.maxAge > 0 && time.Since(rec.EnqueuedAt) > o.maxAge {
+			o.dropped++
+			if err := o.ackLocked(next); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+		return rec, next, nil
+	}
+	return nil, 0, nil
+}
+
+// Requeue moves the record before next to the end of the queue, so a record
+// the API keeps rejecting does not hold back the ones behind it.
+func (o *Outbox) Requeue(rec *OutboxRecord, next int64) error {
+	if err := o.Enqueue(rec); err != nil {
+		return err
+	}
+	return o.Ack(next)
+}
+
+// Ack marks every record before next as delivered.
+func (o *Outbox) Ack(next int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.ackLocked(next)
+}
+
+// Len returns the number of records waiting for delivery.
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.pending
+}
+
+// Dropped returns how many records were evicted by the size or age caps.
+func (o *Outbox) Dropped() uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.dropped
+}
+
+// Notify returns a channel that receives a value whenever a record is enqueued.
+func (o *Outbox) Notify() <-chan struct{} {
+	return o.notify
+}
+
+// Close closes the underlying journal.
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := o.file.Close(); err != nil {
+		return fmt.Errorf("failed to close outbox journal: %w", err)
+	}
+	return nil
+}
+
+func (o *Outbox) peekLocked() (*OutboxRecord, int64, error) {
+	reader := bufio.NewReader(io.NewSectionReader(o.file, o.ack, o.size-o.ack))
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read outbox record: %w", err)
+	}
+	next := o.ack + int64(len(line))
+
+	var rec OutboxRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		// Skip over corrupt records rather than wedging the queue.
+		log.Printf("[outbox] Skipping corrupt record at offset %d: %v", o.ack, err)
+		o.dropped++
+		if aerr := o.ackLocked(next); aerr != nil {
+			return nil, 0, aerr
+		}
+		if o.pending == 0 {
+			return nil, 0, fmt.Errorf("outbox contains only corrupt records")
+		}
+		return o.peekLocked()
+	}
+	return &rec, next, nil
+}
+
+func (o *Outbox) ackLocked(next int64) error {
+	if next <= o.ack {
+		return nil
+	}
+	o.ack = next
+	o.pending--
+
+	switch {
+	case o.pending == 0:
+		// Fully drained: reset the journal instead of letting it grow.
+		if err := o.file.Truncate(0); err != nil {
+			return fmt.Errorf("failed to truncate outbox journal: %w", err)
+		}
+		o.ack, o.size = 0, 0
+	case o.ack >= compactThreshold:
+		if err := o.compactLocked(); err != nil {
+			return err
+		}
+	}
+	return o.saveAckLocked()
+}
+
+// compactLocked rewrites the journal without the already acked prefix. The
+// ack offset is reset before the new journal replaces the old one: a crash in
+// between redelivers the acked prefix instead of resuming mid-record.
+func (o *Outbox) compactLocked() error {
+	tmpPath := o.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, outboxFilePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted outbox: %w", err)
+	}
+	if _, err := io.Copy(tmp, io.NewSectionReader(o.file, o.ack, o.size-o.ack)); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync compacted outbox: %w", err)
+	}
+	if err := writeAck(o.ackPath, 0); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, o.path); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to replace outbox journal: %w", err)
+	}
+	if err := syncDir(filepath.Dir(o.path)); err != nil {
+		log.Printf("[outbox] %v", err)
+	}
+	_ = o.file.Close()
+	o.file = tmp
+	o.size -= o.ack
+	o.ack = 0
+	return nil
+}
+
+func (o *Outbox) saveAckLocked() error {
+	return writeAck(o.ackPath, o.ack)
+}
+
+// writeAck atomically replaces the ack file at path with offset.
+func writeAck(path string, offset int64) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(strconv.FormatInt(offset, 10)), outboxFilePerm); err != nil {
+		return fmt.Errorf("failed to write outbox ack: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to persist outbox ack: %w", err)
+	}
+	return nil
+}
+
+// syncDir flushes a rename in dir to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open outbox dir: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync outbox dir: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package transmitter
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompactedOutboxReopens(t *testing.T) {
+	dir := t.TempDir()
+	o, err := OpenOutbox(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{`"a"`, `"b"`, `"c"`} {
+		if err := o.Enqueue(&OutboxRecord{Endpoint: "/x", EnqueuedAt: time.Now(), Payload: json.RawMessage(v)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, next, err := o.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Ack(next); err != nil {
+		t.Fatal(err)
+	}
+
+	o.mu.Lock()
+	err = o.compactLocked()
+	o.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The ack offset is reset before the journal is replaced; a crash right
+	// after the rename must not leave the old offset behind.
+	data, err := os.ReadFile(filepath.Join(dir, outboxAckName))
+	if err != nil || strings.TrimSpace(string(data)) != "0" {
+		t.Fatalf("ack file = %q, %v; want 0", data, err)
+	}
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	o, err = OpenOutbox(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if n := o.Len(); n != 2 {
+		t.Fatalf("%d records pending after reopening, want 2", n)
+	}
+	rec, _, err := o.Peek()
+	if err != nil || string(rec.Payload) != `"b"` {
+		t.Errorf("head = %v, %v; want \"b\"", rec, err)
+	}
+}
@@ -4,17 +4,29 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"gswarm-sidecar/internal/config"
 )
 
+const (
+	outboxDirName     = "outbox"
+	maxDrainBackoff   = 5 * time.Minute
+	drainPollInterval = 30 * time.Second
+)
+
 type Transmitter struct {
+	mu     sync.RWMutex
 	cfg    *config.Config
 	client *http.Client
+	outbox *Outbox
 }
 
 type MetricsData struct {
@@ -31,78 +43,277 @@ type HealthData struct {
 	Details   string    `json:"details"`
 }
 
+// statusError is a retryable failure status returned by the API.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string { return fmt.Sprintf("API returned status %d", e.code) }
+
+// outage reports whether the status affects every request, such as an
+// expired token, a rate limit or a server error, rather than this record.
+func (e *statusError) outage() bool {
+	switch e.code {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return e.code >= 500
+}
+
+// permanentError marks a delivery failure that retrying will not fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
 func New(cfg *config.Config) *Transmitter {
 	client := &http.Client{
 		Timeout: time.Duration(cfg.API.Timeout) * time.Second,
 	}
 
-	return &Transmitter{
+	t := &Transmitter{
 		cfg:    cfg,
 		client: client,
 	}
+
+	if cfg.Storage.DataPath != "" {
+		dir := filepath.Join(cfg.Storage.DataPath, outboxDirName)
+		maxAge := time.Duration(cfg.Storage.OutboxMaxAge) * time.Second
+		outbox, err := OpenOutbox(dir, cfg.Storage.OutboxMaxBytes, maxAge)
+		if err != nil {
+			log.Printf("[transmitter] Outbox disabled, sending directly: %v", err)
+		} else {
+			log.Printf("[transmitter] Outbox opened at %s with %d pending records", dir, outbox.Len())
+			t.outbox = outbox
+		}
+	}
+
+	return t
 }
 
-func (t *Transmitter) SendJSON(ctx context.Context, endpoint string, payload interface{}, authToken ...string) error {
-	url := fmt.Sprintf("%s%s", t.cfg.API.BaseURL, endpoint)
+// SetConfig applies a reloaded config to subsequent requests, including those
+// for records already queued, which pick up new tokens. The outbox location
+// and limits are kept.
+func (t *Transmitter) SetConfig(cfg *config.Config) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cfg.API.Timeout != t.cfg.API.Timeout {
+		t.client = &http.Client{Timeout: time.Duration(cfg.API.Timeout) * time.Second}
+	}
+	t.cfg = cfg
+}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+func (t *Transmitter) config() (*config.Config, *http.Client) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.cfg, t.client
+}
+
+// Start drains the outbox until ctx is cancelled. It returns immediately when
+// the outbox is disabled.
+func (t *Transmitter) Start(ctx context.Context) {
+	if t.outbox == nil {
+		return
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	backoff := time.Second
+	for {
+		delivered, err := t.drain(ctx)
+		if err != nil {
+			log.Printf("[transmitter] Outbox delivery failed, %d records pending, retrying in %v: %v",
+				t.outbox.Len(), backoff, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxDrainBackoff {
+				backoff = maxDrainBackoff
+			}
+			continue
+		}
+		if delivered > 0 {
+			log.Printf("[transmitter] Delivered %d queued records", delivered)
+		}
+		backoff = time.Second
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.outbox.Notify():
+		case <-time.After(drainPollInterval):
+		}
+	}
+}
+
+// Close releases the outbox. Payloads queued after Start returned stay on disk
+// and are delivered on the next run.
+func (t *Transmitter) Close() {
+	if t.outbox == nil {
+		return
+	}
+	if err := t.outbox.Close(); err != nil {
+		log.Printf("[transmitter] %v", err)
+	}
+}
+
+// Backlog returns the number of payloads waiting in the outbox.
+func (t *Transmitter) Backlog() int {
+	if t.outbox == nil {
+		return 0
+	}
+	return t.outbox.Len()
+}
+
+// drain delivers pending records in order and stops at the first retryable
+// failure, leaving that record at the head of the queue. Only a record the
+// API rejected on its own, with a retryable status that is not an outage, is
+// moved to the end of the queue, so it cannot block the records behind it.
+func (t *Transmitter) drain(ctx context.Context) (int, error) {
+	delivered := 0
+	for ctx.Err() == nil {
+		rec, next, err := t.outbox.Peek()
+		if err != nil {
+			return delivered, err
+		}
+		if rec == nil {
+			return delivered, nil
+		}
+
+		err = t.send(ctx, rec.Endpoint, rec.Payload, rec.UseJWT || rec.LegacyAuthToken != "")
+		var perm *permanentError
+		var status *statusError
+		switch {
+		case err == nil:
+			delivered++
+		case errors.As(err, &perm):
+			log.Printf("[transmitter] Dropping undeliverable record for %s: %v", rec.Endpoint, err)
+		case errors.As(err, &status) && !status.outage():
+			if rerr := t.outbox.Requeue(rec, next); rerr != nil {
+				return delivered, rerr
+			}
+			return delivered, err
+		default:
+			return delivered, err
+		}
+		if err := t.outbox.Ack(next); err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+// SendJSON queues payload for delivery to endpoint, authenticated with
+// jwt_token when useJWT is set and api.auth_token otherwise. With the outbox
+// enabled it returns once the payload is synced to disk; otherwise it sends
+// directly.
+func (t *Transmitter) SendJSON(ctx context.Context, endpoint string, payload interface{}, useJWT bool) error {
+	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	if len(authToken) > 0 && authToken[0] != "" {
-		req.Header.Set("Authorization", "Bearer "+authToken[0])
-	} else if t.cfg.API.AuthToken != "" {
-		req.Header.Set("Authorization", "Bearer "+t.cfg.API.AuthToken)
+	if t.outbox != nil {
+		err := t.outbox.Enqueue(&OutboxRecord{
+			Endpoint:   endpoint,
+			UseJWT:     useJWT,
+			EnqueuedAt: time.Now(),
+			Payload:    jsonData,
+		})
+		if err == nil {
+			return nil
+		}
+		log.Printf("[transmitter] Failed to queue payload, sending directly: %v", err)
 	}
 
-	return t.sendWithRetry(req)
+	return t.sendWithRetry(ctx, endpoint, jsonData, useJWT)
 }
 
 func (t *Transmitter) SendMetrics(ctx context.Context, data *MetricsData) error {
-	return t.SendJSON(ctx, t.cfg.API.MetricsEndpoint, data)
+	cfg, _ := t.config()
+	return t.SendJSON(ctx, cfg.API.MetricsEndpoint, data, false)
 }
 
 func (t *Transmitter) SendHealth(ctx context.Context, data *HealthData) error {
-	return t.SendJSON(ctx, t.cfg.API.HealthEndpoint, data)
+	cfg, _ := t.config()
+	return t.SendJSON(ctx, cfg.API.HealthEndpoint, data, false)
 }
 
-func (t *Transmitter) sendWithRetry(req *http.Request) error {
+func (t *Transmitter) sendWithRetry(ctx context.Context, endpoint string, body []byte, useJWT bool) error {
 	var lastErr error
 
-	for i := 0; i <= t.cfg.API.RetryCount; i++ {
-		resp, err := t.client.Do(req)
-		if err != nil {
-			lastErr = fmt.Errorf("request failed: %w", err)
-			if i < t.cfg.API.RetryCount {
-				time.Sleep(time.Duration(i+1) * time.Second)
-				continue
-			}
-			return lastErr
-		}
-
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			if err := resp.Body.Close(); err != nil {
-				log.Printf("failed to close response body: %v", err)
-			}
+	cfg, _ := t.config()
+	for i := 0; i <= cfg.API.RetryCount; i++ {
+		lastErr = t.send(ctx, endpoint, body, useJWT)
+		if lastErr == nil {
 			return nil
 		}
-
-		lastErr = fmt.Errorf("API returned status %d", resp.StatusCode)
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("failed to close response body: %v", err)
+		var perm *permanentError
+		if errors.As(lastErr, &perm) {
+			return lastErr
 		}
-		if i < t.cfg.API.RetryCount {
-			time.Sleep(time.Duration(i+1) * time.Second)
-			continue
+		if i < cfg.API.RetryCount {
+			select {
+			case <-ctx.Done():
+				return lastErr
+			case <-time.After(time.Duration(i+1) * time.Second):
+			}
 		}
 	}
 
 	return lastErr
 }
+
+// send performs a single POST of body to endpoint.
+func (t *Transmitter) send(ctx context.Context, endpoint string, body []byte, useJWT bool) error {
+	cfg, client := t.config()
+	url := endpoint
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		url = fmt.Sprintf("%s%s", cfg.API.BaseURL, endpoint)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{fmt.Errorf("failed to create request: %w", err)}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if useJWT && cfg.JWTToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.JWTToken)
+	} else if cfg.API.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.API.AuthToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		log.Printf("failed to close response body: %v", err)
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case isPermanentStatus(resp.StatusCode):
+		return &permanentError{fmt.Errorf("API returned status %d", resp.StatusCode)}
+	default:
+		return &statusError{code: resp.StatusCode}
+	}
+}
+
+// isPermanentStatus reports whether a response status means the payload itself
+// was rejected. Auth failures, timeouts and rate limits are worth retrying.
+func isPermanentStatus(code int) bool {
+	if code < 400 || code >= 500 {
+		return false
+	}
+	switch code {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+package transmitter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gswarm-sidecar/internal/config"
+)
+
+func newTestTransmitter(t *testing.T, url string) *Transmitter {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.API.BaseURL = url
+	cfg.API.Timeout = 5
+	cfg.JWTToken = "jwt-1"
+	cfg.Storage.DataPath = t.TempDir()
+	tr := New(cfg)
+	if tr.outbox == nil {
+		t.Fatal("outbox not opened")
+	}
+	t.Cleanup(tr.Close)
+	return tr
+}
+
+func TestOutboxDoesNotStoreTokens(t *testing.T) {
+	tr := newTestTransmitter(t, "http://127.0.0.1:1")
+	if err := tr.SendJSON(context.Background(), "/logs", map[string]string{"k": "v"}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(tr.cfg.Storage.DataPath, outboxDirName, outboxFileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "jwt-1") {
+		t.Errorf("journal contains the token: %s", data)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != outboxFilePerm {
+		t.Errorf("journal permissions = %o, want %o", perm, outboxFilePerm)
+	}
+}
+
+// recordingServer answers each request with status(body) and records the
+// bodies and Authorization headers it saw.
+func recordingServer(t *testing.T, status func(body string) int) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, string(body)+" "+r.Header.Get("Authorization"))
+		mu.Unlock()
+		w.WriteHeader(status(string(body)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), got...)
+	}
+}
+
+func TestDrainKeepsOrderDuringOutage(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusForbidden
+	srv, requests := recordingServer(t, func(string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return status
+	})
+
+	tr := newTestTransmitter(t, srv.URL)
+	ctx := context.Background()
+	for _, v := range []string{"a", "b", "c"} {
+		if err := tr.SendJSON(ctx, "/x", v, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, code := range []int{http.StatusForbidden, http.StatusServiceUnavailable} {
+		mu.Lock()
+		status = code
+		mu.Unlock()
+		if _, err := tr.drain(ctx); err == nil {
+			t.Fatalf("drain succeeded during a %d outage", code)
+		}
+	}
+
+	// The token is rotated and the API recovers.
+	reloaded := *tr.cfg
+	reloaded.JWTToken = "jwt-2"
+	tr.SetConfig(&reloaded)
+	mu.Lock()
+	status = http.StatusOK
+	mu.Unlock()
+	if delivered, err := tr.drain(ctx); err != nil || delivered != 3 {
+		t.Fatalf("delivered %d records, %v; want all 3", delivered, err)
+	}
+
+	want := []string{`"a" Bearer jwt-1`, `"a" Bearer jwt-1`, `"a" Bearer jwt-2`, `"b" Bearer jwt-2`, `"c" Bearer jwt-2`}
+	if got := requests(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
+
+func TestDrainRequeuesIndividuallyRejectedRecord(t *testing.T) {
+	srv, requests := recordingServer(t, func(body string) int {
+		if body == `"bad"` {
+			return http.StatusMultipleChoices
+		}
+		return http.StatusOK
+	})
+
+	tr := newTestTransmitter(t, srv.URL)
+	ctx := context.Background()
+	for _, v := range []string{"bad", "good"} {
+		if err := tr.SendJSON(ctx, "/x", v, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := tr.drain(ctx); err == nil {
+		t.Fatal("drain succeeded despite the rejected record")
+	}
+	if delivered, _ := tr.drain(ctx); delivered != 1 {
+		t.Fatalf("delivered %d records after the rejected one, want 1", delivered)
+	}
+	if n := tr.Backlog(); n != 1 {
+		t.Errorf("backlog = %d, want the rejected record only", n)
+	}
+	if got := requests(); len(got) < 2 || got[0] != `"bad" ` || got[1] != `"good" ` {
+		t.Errorf("requests = %q, want bad then good", got)
+	}
+}
+
+func TestSendWithRetryStopsOnCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	tr := newTestTransmitter(t, srv.URL)
+	tr.cfg.API.RetryCount = 5
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := tr.sendWithRetry(ctx, "/x", []byte("{}"), false); err == nil {
+		t.Fatal("expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("sendWithRetry took %v after the context was cancelled", elapsed)
+	}
+}